@@ -0,0 +1,53 @@
+// Package auth identifies the users of the editor, either from a users file
+// with passwords and session cookies or from headers set by a reverse proxy.
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// User is someone allowed to use the editor. Their name and email are used
+// as the author of the commits they make.
+type User struct {
+	Username string `yaml:"username"`
+	Name     string `yaml:"name"`
+	Email    string `yaml:"email"`
+
+	// Session is set for users logged in with a session they can end by
+	// logging out
+	Session bool `yaml:"-"`
+}
+
+// Author returns the user in the "Name <email>" form git expects
+func (u User) Author() string {
+	name := u.Name
+	if name == "" {
+		name = u.Username
+	}
+	return authorField.Replace(name) + " <" + authorField.Replace(u.Email) + ">"
+}
+
+// authorField drops the characters that would break up a git author
+var authorField = strings.NewReplacer("<", "", ">", "", "\n", " ", "\r", " ")
+
+// Authenticator identifies the user making a request
+type Authenticator interface {
+	// User returns the user the request is made by, false when it isn't
+	// authenticated
+	User(r *http.Request) (User, bool)
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying user
+func NewContext(ctx context.Context, user User) context.Context {
+	return context.WithValue(ctx, contextKey{}, user)
+}
+
+// FromContext returns the user stored in ctx by NewContext
+func FromContext(ctx context.Context) (User, bool) {
+	user, ok := ctx.Value(contextKey{}).(User)
+	return user, ok
+}
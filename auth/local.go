@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// SessionCookie is the name of the cookie holding the session token
+	SessionCookie = "hugs_session"
+
+	// sessionLifetime is how long a session lasts after its last use
+	sessionLifetime = 7 * 24 * time.Hour
+)
+
+// ErrInvalidLogin is returned for an unknown user or a wrong password
+var ErrInvalidLogin = errors.New("invalid username or password")
+
+// dummyHash is compared against for unknown users, so they take as long to
+// reject as a wrong password
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("hugs"), bcrypt.DefaultCost)
+
+// account is a user as listed in the users file
+type account struct {
+	User     `yaml:",inline"`
+	Password string `yaml:"password"` // bcrypt hash
+}
+
+type session struct {
+	user    User
+	expires time.Time
+}
+
+// Local authenticates the users listed in a users file with their password,
+// keeping them logged in with a session cookie. Sessions are kept in memory
+// and end when the editor restarts.
+type Local struct {
+	accounts map[string]account
+
+	mu       sync.Mutex
+	sessions map[string]session
+}
+
+// NewLocal reads the users file at path, a YAML document like
+//
+//	users:
+//	  - username: jane
+//	    name: Jane Doe
+//	    email: jane@example.com
+//	    password: $2a$10$... # from `hugs hash-password`
+func NewLocal(path string) (*Local, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading users file: %w", err)
+	}
+
+	var file struct {
+		Users []account `yaml:"users"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parsing users file %s: %w", path, err)
+	}
+
+	accounts := make(map[string]account, len(file.Users))
+	for _, account := range file.Users {
+		if account.Username == "" || account.Password == "" {
+			return nil, fmt.Errorf("users file %s: every user needs a username and password", path)
+		}
+		if _, err := bcrypt.Cost([]byte(account.Password)); err != nil {
+			return nil, fmt.Errorf("users file %s: password of %s is not a bcrypt hash: %w", path, account.Username, err)
+		}
+		accounts[account.Username] = account
+	}
+	if len(accounts) == 0 {
+		return nil, fmt.Errorf("users file %s lists no users", path)
+	}
+
+	log.Info().Int("users", len(accounts)).Str("path", path).Msg("Loaded users")
+	return &Local{
+		accounts: accounts,
+		sessions: make(map[string]session),
+	}, nil
+}
+
+// HashPassword returns the bcrypt hash of password for the users file
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("hashing password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// User returns the user of the request's session
+func (l *Local) User(r *http.Request) (User, bool) {
+	cookie, err := r.Cookie(SessionCookie)
+	if err != nil {
+		return User{}, false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	session, ok := l.sessions[cookie.Value]
+	if !ok {
+		return User{}, false
+	}
+	if time.Now().After(session.expires) {
+		delete(l.sessions, cookie.Value)
+		return User{}, false
+	}
+
+	session.expires = time.Now().Add(sessionLifetime)
+	l.sessions[cookie.Value] = session
+
+	user := session.user
+	user.Session = true
+	return user, true
+}
+
+// Login checks the password of username and starts a session for them,
+// setting its cookie on w
+func (l *Local) Login(w http.ResponseWriter, r *http.Request, username, password string) (User, error) {
+	account, ok := l.accounts[username]
+	hash := []byte(account.Password)
+	if !ok {
+		hash = dummyHash
+	}
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil || !ok {
+		log.Warn().Str("username", username).Msg("Failed login")
+		return User{}, ErrInvalidLogin
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return User{}, err
+	}
+
+	l.mu.Lock()
+	l.sessions[token] = session{user: account.User, expires: time.Now().Add(sessionLifetime)}
+	l.removeExpired()
+	l.mu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(sessionLifetime.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	log.Info().Str("username", username).Msg("User logged in")
+	return account.User, nil
+}
+
+// Logout ends the request's session and clears its cookie
+func (l *Local) Logout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(SessionCookie); err == nil {
+		l.mu.Lock()
+		delete(l.sessions, cookie.Value)
+		l.mu.Unlock()
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// removeExpired drops sessions that ran out, l.mu must be held
+func (l *Local) removeExpired() {
+	now := time.Now()
+	for token, session := range l.sessions {
+		if now.After(session.expires) {
+			delete(l.sessions, token)
+		}
+	}
+}
+
+// newToken returns a random session token
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating session token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Proxy trusts the identity a reverse proxy in front of the editor, such as
+// oauth2-proxy, passes in request headers. The editor must then only be
+// reachable through that proxy, e.g. by listening on 127.0.0.1, or anyone
+// can claim to be any user by setting the headers themselves.
+type Proxy struct {
+	UserHeader  string // e.g. X-Forwarded-User
+	EmailHeader string // e.g. X-Forwarded-Email, optional
+	NameHeader  string // e.g. X-Forwarded-Preferred-Username, optional
+}
+
+// User returns the user named in the request's headers
+func (p *Proxy) User(r *http.Request) (User, bool) {
+	username := strings.TrimSpace(r.Header.Get(p.UserHeader))
+	if username == "" {
+		return User{}, false
+	}
+
+	user := User{Username: username}
+	if p.EmailHeader != "" {
+		user.Email = strings.TrimSpace(r.Header.Get(p.EmailHeader))
+	}
+	if p.NameHeader != "" {
+		user.Name = strings.TrimSpace(r.Header.Get(p.NameHeader))
+	}
+	return user, true
+}
@@ -0,0 +1,138 @@
+// Package certs creates the self-signed certificate the editor serves HTTPS
+// with when no certificate is provided.
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// validity is how long generated certificates are valid for
+const validity = 2 * 365 * 24 * time.Hour
+
+// EnsureSelfSigned creates a self-signed certificate and its key at
+// certFile and keyFile, unless both exist already. The certificate is valid
+// for localhost, the machine's hostname and its IP addresses, so the editor
+// can be reached from other machines on the network.
+func EnsureSelfSigned(certFile, keyFile string) error {
+	_, certErr := os.Stat(certFile)
+	_, keyErr := os.Stat(keyFile)
+	if certErr == nil && keyErr == nil {
+		return nil
+	}
+	if !errors.Is(certErr, fs.ErrNotExist) && certErr != nil {
+		return fmt.Errorf("checking certificate: %w", certErr)
+	}
+	if !errors.Is(keyErr, fs.ErrNotExist) && keyErr != nil {
+		return fmt.Errorf("checking key: %w", keyErr)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("generating key: %w", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return fmt.Errorf("generating serial number: %w", err)
+	}
+
+	dnsNames, ips := hosts()
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"hugs"}, CommonName: dnsNames[0]},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              dnsNames,
+		IPAddresses:           ips,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return fmt.Errorf("creating certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("encoding key: %w", err)
+	}
+
+	if err := writePEM(keyFile, "PRIVATE KEY", keyDER, 0o600); err != nil {
+		return err
+	}
+	if err := writePEM(certFile, "CERTIFICATE", der, 0o644); err != nil {
+		return err
+	}
+
+	log.Info().
+		Str("cert", certFile).
+		Strs("hosts", dnsNames).
+		Str("expires", template.NotAfter.Format(time.DateOnly)).
+		Msg("Generated self-signed certificate")
+	return nil
+}
+
+// DefaultDir returns the directory generated certificates are kept in
+func DefaultDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("finding config directory: %w", err)
+	}
+	return filepath.Join(dir, "hugs"), nil
+}
+
+// hosts returns the names and addresses the certificate is issued for
+func hosts() ([]string, []net.IP) {
+	dnsNames := []string{"localhost"}
+	if hostname, err := os.Hostname(); err == nil && hostname != "localhost" {
+		dnsNames = append(dnsNames, hostname)
+	}
+
+	ips := []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to list network addresses")
+		return dnsNames, ips
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && !ipNet.IP.IsLinkLocalUnicast() {
+			ips = append(ips, ipNet.IP)
+		}
+	}
+	return dnsNames, ips
+}
+
+func writePEM(path, blockType string, der []byte, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("creating directory for %s: %w", path, err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", path, err)
+	}
+	if err := pem.Encode(file, &pem.Block{Type: blockType, Bytes: der}); err != nil {
+		file.Close()
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+// Package csrf protects state changing requests against cross-site request
+// forgery. Every browser gets a random token in a cookie, which forms must
+// send back in a hidden field (or scripts in a header) for requests other
+// than GET and HEAD to be accepted.
+package csrf
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// FieldName is the form field carrying the token
+	FieldName = "csrf_token"
+
+	// HeaderName is the request header carrying the token, for scripts
+	HeaderName = "X-CSRF-Token"
+
+	cookieName = "hugs_csrf"
+)
+
+// FailureFunc responds to a request rejected for the given reason
+type FailureFunc func(w http.ResponseWriter, r *http.Request, reason string)
+
+type contextKey struct{}
+
+// Token returns the CSRF token of the request ctx belongs to, for embedding
+// in forms
+func Token(ctx context.Context) string {
+	token, _ := ctx.Value(contextKey{}).(string)
+	return token
+}
+
+// Protect hands requests to next with their token in the context, issuing
+// a token to browsers that don't have one yet. Unsafe requests without the
+// matching token are passed to failure instead.
+func Protect(next http.Handler, failure FailureFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var token string
+		if cookie, err := r.Cookie(cookieName); err == nil && cookie.Value != "" {
+			token = cookie.Value
+		}
+
+		if !safeMethod(r.Method) {
+			if token == "" {
+				reject(w, r, failure, "it was sent without a CSRF cookie")
+				return
+			}
+
+			sent := r.Header.Get(HeaderName)
+			if sent == "" {
+				sent = r.FormValue(FieldName)
+			}
+			if subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+				reject(w, r, failure, "its CSRF token doesn't match")
+				return
+			}
+		}
+
+		if token == "" {
+			var err error
+			token, err = newToken()
+			if err != nil {
+				log.Error().Err(err).Msg("Error generating CSRF token")
+				http.Error(w, "Error generating CSRF token: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			http.SetCookie(w, &http.Cookie{
+				Name:     cookieName,
+				Value:    token,
+				Path:     "/",
+				HttpOnly: true,
+				Secure:   r.TLS != nil,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, token)))
+	})
+}
+
+func reject(w http.ResponseWriter, r *http.Request, failure FailureFunc, reason string) {
+	log.Warn().Str("method", r.Method).Str("path", r.URL.Path).Str("reason", reason).Msg("Rejected request")
+	failure(w, r, reason)
+}
+
+// safeMethod reports whether requests with method don't change anything
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
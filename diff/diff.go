@@ -0,0 +1,145 @@
+// Package diff compares two versions of a text line by line.
+package diff
+
+import "strings"
+
+// Op is the kind of change a line represents
+type Op int
+
+const (
+	Equal  Op = iota // line is in both versions
+	Delete           // line is only in the old version
+	Insert           // line is only in the new version
+)
+
+// maxCells bounds the size of the comparison table. Larger inputs are
+// reported as a replacement of every line instead of a minimal diff.
+const maxCells = 4_000_000
+
+// Line is a single line of a diff
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// Lines returns the line diff turning a into b
+func Lines(a, b string) []Line {
+	before, after := split(a), split(b)
+
+	// Leave out the common prefix and suffix, usually most of a post
+	prefix := 0
+	for prefix < len(before) && prefix < len(after) && before[prefix] == after[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(before)-prefix && suffix < len(after)-prefix &&
+		before[len(before)-1-suffix] == after[len(after)-1-suffix] {
+		suffix++
+	}
+
+	var lines []Line
+	for _, text := range before[:prefix] {
+		lines = append(lines, Line{Equal, text})
+	}
+	lines = append(lines, lcs(before[prefix:len(before)-suffix], after[prefix:len(after)-suffix])...)
+	for _, text := range before[len(before)-suffix:] {
+		lines = append(lines, Line{Equal, text})
+	}
+	return lines
+}
+
+// lcs diffs two slices of lines through their longest common subsequence
+func lcs(a, b []string) []Line {
+	var lines []Line
+
+	if len(a)*len(b) > maxCells {
+		for _, text := range a {
+			lines = append(lines, Line{Delete, text})
+		}
+		for _, text := range b {
+			lines = append(lines, Line{Insert, text})
+		}
+		return lines
+	}
+
+	// table[i][j] is the length of the LCS of a[i:] and b[j:]
+	width := len(b) + 1
+	table := make([]int32, (len(a)+1)*width)
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				table[i*width+j] = table[(i+1)*width+j+1] + 1
+			} else {
+				table[i*width+j] = max(table[(i+1)*width+j], table[i*width+j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, Line{Equal, a[i]})
+			i++
+			j++
+		case table[(i+1)*width+j] >= table[i*width+j+1]:
+			lines = append(lines, Line{Delete, a[i]})
+			i++
+		default:
+			lines = append(lines, Line{Insert, b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, Line{Delete, a[i]})
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, Line{Insert, b[j]})
+	}
+	return lines
+}
+
+// Merge combines two versions of a text, keeping the lines they share and
+// wrapping each region where they differ in git style conflict markers
+func Merge(ours, theirs, oursLabel, theirsLabel string) string {
+	var b strings.Builder
+	var ourLines, theirLines []string
+
+	flush := func() {
+		if len(ourLines) == 0 && len(theirLines) == 0 {
+			return
+		}
+		b.WriteString("<<<<<<< " + oursLabel + "\n")
+		for _, text := range ourLines {
+			b.WriteString(text + "\n")
+		}
+		b.WriteString("=======\n")
+		for _, text := range theirLines {
+			b.WriteString(text + "\n")
+		}
+		b.WriteString(">>>>>>> " + theirsLabel + "\n")
+		ourLines, theirLines = nil, nil
+	}
+
+	for _, line := range Lines(ours, theirs) {
+		switch line.Op {
+		case Equal:
+			flush()
+			b.WriteString(line.Text + "\n")
+		case Delete:
+			ourLines = append(ourLines, line.Text)
+		case Insert:
+			theirLines = append(theirLines, line.Text)
+		}
+	}
+	flush()
+
+	return b.String()
+}
+
+func split(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
@@ -0,0 +1,107 @@
+// Package flash carries a one-off message, such as the outcome of a save,
+// over a redirect to the next page the browser loads.
+package flash
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Kind tells how a message is shown
+type Kind string
+
+const (
+	Success Kind = "success"
+	Error   Kind = "error"
+)
+
+// Message is a flash message
+type Message struct {
+	Kind Kind   `json:"kind"`
+	Text string `json:"text"`
+}
+
+const cookieName = "hugs_flash"
+
+// maxText bounds the length of a message, browsers drop cookies over 4KB
+const maxText = 2000
+
+type contextKey struct{}
+
+// FromContext returns the flash message of the page being rendered
+func FromContext(ctx context.Context) (Message, bool) {
+	message, ok := ctx.Value(contextKey{}).(Message)
+	return message, ok
+}
+
+// Set stores a message for the next page the browser loads, replacing any
+// message it hasn't been shown yet
+func Set(w http.ResponseWriter, r *http.Request, kind Kind, text string) {
+	if len(text) > maxText {
+		text = strings.ToValidUTF8(text[:maxText], "") + "…"
+	}
+	value, err := json.Marshal(Message{Kind: kind, Text: text})
+	if err != nil {
+		log.Error().Err(err).Msg("Error encoding flash message")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieName,
+		Value:    base64.RawURLEncoding.EncodeToString(value),
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// Load hands page loads to next with the pending message, if any, in the
+// context and removes it from the browser. Other requests, like scripts
+// fetching data, leave the message for the page.
+func Load(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(cookieName)
+		if err != nil || !pageLoad(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     cookieName,
+			Path:     "/",
+			MaxAge:   -1,
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		})
+
+		var message Message
+		value, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+		if err == nil {
+			err = json.Unmarshal(value, &message)
+		}
+		if err != nil {
+			log.Debug().Err(err).Msg("Ignoring invalid flash message")
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, message)))
+	})
+}
+
+// pageLoad reports whether r loads a page, rather than something on it
+func pageLoad(r *http.Request) bool {
+	if r.Method != http.MethodGet {
+		return false
+	}
+	if mode := r.Header.Get("Sec-Fetch-Mode"); mode != "" {
+		return mode == "navigate"
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
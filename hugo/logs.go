@@ -0,0 +1,40 @@
+package hugo
+
+import "sync"
+
+// logLines is how many lines of Hugo's output are kept
+const logLines = 200
+
+// logBuffer keeps the most recent lines written to it
+type logBuffer struct {
+	mu    sync.Mutex
+	buf   []string
+	next  int // index the next line is written to
+	count int
+}
+
+func newLogBuffer(size int) *logBuffer {
+	return &logBuffer{buf: make([]string, size)}
+}
+
+func (b *logBuffer) add(line string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.buf[b.next] = line
+	b.next = (b.next + 1) % len(b.buf)
+	b.count = min(b.count+1, len(b.buf))
+}
+
+// lines returns the kept lines, oldest first
+func (b *logBuffer) lines() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	lines := make([]string, 0, b.count)
+	start := (b.next - b.count + len(b.buf)) % len(b.buf)
+	for i := range b.count {
+		lines = append(lines, b.buf[(start+i)%len(b.buf)])
+	}
+	return lines
+}
@@ -0,0 +1,34 @@
+//go:build !windows
+
+package hugo
+
+import (
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// setProcessGroup starts Hugo in a process group of its own, so stopping it
+// also stops anything it started
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// terminate asks Hugo's process group to exit and kills it when it hasn't
+// within the grace period. exited receives the result of cmd.Wait.
+func terminate(cmd *exec.Cmd, exited <-chan error) {
+	pgid := -cmd.Process.Pid
+	if err := syscall.Kill(pgid, syscall.SIGTERM); err != nil {
+		log.Debug().Err(err).Msg("Failed to signal Hugo server")
+	}
+
+	select {
+	case <-exited:
+	case <-time.After(gracePeriod):
+		log.Warn().Msg("Hugo server didn't exit, killing it")
+		syscall.Kill(pgid, syscall.SIGKILL)
+		<-exited
+	}
+}
@@ -0,0 +1,14 @@
+//go:build windows
+
+package hugo
+
+import "os/exec"
+
+// setProcessGroup is a no-op, Windows has no process groups to signal
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// terminate kills Hugo. exited receives the result of cmd.Wait.
+func terminate(cmd *exec.Cmd, exited <-chan error) {
+	cmd.Process.Kill()
+	<-exited
+}
@@ -0,0 +1,284 @@
+// Package hugo runs the Hugo development server as a supervised child
+// process of the editor.
+package hugo
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// readyInterval is how often a starting server is checked for a
+	// listening port
+	readyInterval = 200 * time.Millisecond
+
+	// minBackoff and maxBackoff bound the wait before restarting Hugo
+	minBackoff = time.Second
+	maxBackoff = 30 * time.Second
+
+	// stableAfter is how long Hugo has to run before a crash restarts it
+	// without waiting for the backoff built up by earlier crashes
+	stableAfter = time.Minute
+
+	// gracePeriod is how long Hugo gets to exit after being asked to stop,
+	// before it is killed
+	gracePeriod = 5 * time.Second
+)
+
+// State is the lifecycle state of the supervised server
+type State int
+
+const (
+	Stopped    State = iota // not started, or stopped for good
+	Starting                // running but not listening yet
+	Running                 // listening for requests
+	Restarting              // exited, waiting to be started again
+)
+
+func (s State) String() string {
+	switch s {
+	case Starting:
+		return "starting"
+	case Running:
+		return "running"
+	case Restarting:
+		return "restarting"
+	default:
+		return "stopped"
+	}
+}
+
+// Config describes how Hugo is run
+type Config struct {
+	Command string // the hugo binary, "hugo" when empty
+	Dir     string // root of the Hugo site
+
+	Port           string // port Hugo listens on
+	BaseURL        string // URL the site is reached at
+	LiveReloadPort string // port browsers connect to for live reload
+}
+
+// Status is a snapshot of the supervised server
+type Status struct {
+	State     State
+	Since     time.Time // when State was entered
+	PID       int       // process ID while running, 0 otherwise
+	Restarts  int
+	LastError string   // why Hugo last exited
+	Logs      []string // recent output, oldest first
+}
+
+// Supervisor runs `hugo server`, waits for it to listen, restarts it with a
+// growing backoff when it exits and stops its whole process group on
+// shutdown
+type Supervisor struct {
+	cfg  Config
+	logs *logBuffer
+
+	mu       sync.Mutex
+	state    State
+	since    time.Time
+	pid      int
+	restarts int
+	lastErr  error
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New returns a supervisor for the Hugo server described by cfg
+func New(cfg Config) *Supervisor {
+	if cfg.Command == "" {
+		cfg.Command = "hugo"
+	}
+	return &Supervisor{
+		cfg:   cfg,
+		logs:  newLogBuffer(logLines),
+		since: time.Now(),
+	}
+}
+
+// URL returns the address Hugo listens on
+func (s *Supervisor) URL() string {
+	return "http://" + net.JoinHostPort("localhost", s.cfg.Port)
+}
+
+// Start runs Hugo in the background until ctx is done or Stop is called
+func (s *Supervisor) Start(ctx context.Context) {
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.done = make(chan struct{})
+	go s.run(ctx)
+}
+
+// Stop terminates Hugo and waits for it to exit
+func (s *Supervisor) Stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	<-s.done
+}
+
+// Status returns the current state of the server and its recent output
+func (s *Supervisor) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := Status{
+		State:    s.state,
+		Since:    s.since,
+		PID:      s.pid,
+		Restarts: s.restarts,
+		Logs:     s.logs.lines(),
+	}
+	if s.lastErr != nil {
+		status.LastError = s.lastErr.Error()
+	}
+	return status
+}
+
+// Ready reports whether Hugo is listening for requests
+func (s *Supervisor) Ready() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state == Running
+}
+
+func (s *Supervisor) setState(state State, pid int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = state
+	s.since = time.Now()
+	s.pid = pid
+}
+
+// run starts Hugo again each time it exits, until ctx is done
+func (s *Supervisor) run(ctx context.Context) {
+	defer close(s.done)
+	defer s.setState(Stopped, 0)
+
+	backoff := minBackoff
+	for {
+		started := time.Now()
+		err := s.runOnce(ctx)
+		if ctx.Err() != nil {
+			log.Info().Msg("Hugo server stopped")
+			return
+		}
+
+		// A server that ran for a while crashed for a new reason
+		if time.Since(started) > stableAfter {
+			backoff = minBackoff
+		}
+
+		s.mu.Lock()
+		s.restarts++
+		s.lastErr = err
+		s.mu.Unlock()
+		s.setState(Restarting, 0)
+		log.Error().Err(err).Dur("backoff", backoff).Msg("Hugo server exited, restarting")
+
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("Hugo server stopped")
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// runOnce runs Hugo until it exits or ctx is done
+func (s *Supervisor) runOnce(ctx context.Context) error {
+	cmd := exec.Command(s.cfg.Command, "server", "-D",
+		"--port", s.cfg.Port,
+		"--baseURL", s.cfg.BaseURL,
+		"--appendPort=false",
+		"--liveReloadPort", s.cfg.LiveReloadPort,
+	)
+	cmd.Dir = s.cfg.Dir
+	setProcessGroup(cmd)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("getting stdout pipe: %w", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("getting stderr pipe: %w", err)
+	}
+
+	log.Debug().Str("hugo_dir", cmd.Dir).Strs("args", cmd.Args).Msg("Starting Hugo server")
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("starting hugo: %w", err)
+	}
+	s.setState(Starting, cmd.Process.Pid)
+
+	// Wait only returns once the output has been read completely
+	var output sync.WaitGroup
+	output.Add(2)
+	go s.readOutput(&output, stdout, false)
+	go s.readOutput(&output, stderr, true)
+
+	exited := make(chan error, 1)
+	go func() {
+		output.Wait()
+		exited <- cmd.Wait()
+	}()
+
+	ready := time.NewTicker(readyInterval)
+	defer ready.Stop()
+	for {
+		select {
+		case err := <-exited:
+			if err == nil {
+				err = errors.New("hugo exited")
+			}
+			return err
+		case <-ctx.Done():
+			terminate(cmd, exited)
+			return ctx.Err()
+		case <-ready.C:
+			if s.Ready() || !s.listening() {
+				continue
+			}
+			s.setState(Running, cmd.Process.Pid)
+			log.Info().Str("url", s.cfg.BaseURL).Msg("Hugo server ready")
+		}
+	}
+}
+
+// listening reports whether something accepts connections on Hugo's port
+func (s *Supervisor) listening() bool {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort("localhost", s.cfg.Port), readyInterval)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// readOutput logs each line Hugo writes and keeps it for the status page
+func (s *Supervisor) readOutput(wg *sync.WaitGroup, r io.Reader, isErr bool) {
+	defer wg.Done()
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		s.logs.add(line)
+		if isErr {
+			log.Error().Str("source", "hugo").Msg(line)
+		} else {
+			log.Info().Str("source", "hugo").Msg(line)
+		}
+	}
+}
@@ -2,17 +2,31 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
 	"os"
-	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/ionrock/hugs/auth"
+	"github.com/ionrock/hugs/certs"
+	"github.com/ionrock/hugs/hugo"
+	"github.com/ionrock/hugs/vcs"
 	"github.com/ionrock/hugs/web"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/urfave/cli/v2"
+	"golang.org/x/term"
 )
 
+// shutdownTimeout bounds how long in-flight requests are waited for on exit
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	// Configure zerolog
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
@@ -33,23 +47,95 @@ func main() {
 				Value:   "8080",
 				Usage:   "Port to run the server on",
 			},
+			&cli.StringFlag{
+				Name:    "listen",
+				Aliases: []string{"l"},
+				Usage:   "Address to listen on, e.g. 127.0.0.1:8080 (overrides --port)",
+			},
 			&cli.StringFlag{
 				Name:    "content-dir",
 				Aliases: []string{"d"},
-				Usage:   "Path to the content/post directory (defaults to ./content/post)",
+				Usage:   "Path to the Hugo site whose content/ directory is edited (defaults to the current directory)",
 			},
 			&cli.BoolFlag{
 				Name:    "debug",
 				Aliases: []string{"v"},
 				Usage:   "Enable debug logging",
 			},
+			&cli.StringFlag{
+				Name:  "tls-cert",
+				Usage: "Certificate file to serve HTTPS with",
+			},
+			&cli.StringFlag{
+				Name:  "tls-key",
+				Usage: "Private key file of the certificate",
+			},
+			&cli.BoolFlag{
+				Name:  "tls-self-signed",
+				Usage: "Serve HTTPS with a self-signed certificate, generated on first run (kept at --tls-cert and --tls-key, or in the user config directory)",
+			},
+			&cli.StringFlag{
+				Name:  "http-redirect",
+				Usage: "With TLS, also listen for plain HTTP on this address, e.g. :80, and redirect it to HTTPS",
+			},
+			&cli.StringFlag{
+				Name:  "users",
+				Usage: "YAML file of the users allowed to log in, with bcrypt password hashes (see hash-password)",
+			},
+			&cli.StringFlag{
+				Name:  "auth-header",
+				Usage: "Trust the username in this header set by a reverse proxy, e.g. X-Forwarded-User, instead of logging users in",
+			},
+			&cli.StringFlag{
+				Name:  "auth-email-header",
+				Value: "X-Forwarded-Email",
+				Usage: "Header holding the email of the user authenticated by the reverse proxy",
+			},
+			&cli.StringFlag{
+				Name:  "auth-name-header",
+				Usage: "Header holding the display name of the user authenticated by the reverse proxy",
+			},
+			&cli.StringFlag{
+				Name:  "sync-strategy",
+				Value: string(vcs.Rebase),
+				Usage: "How changes pushed from elsewhere are brought in before pushing: \"rebase\" or \"merge\"",
+			},
+			&cli.DurationFlag{
+				Name:  "sync-interval",
+				Usage: "Also bring in changes pushed from elsewhere this often, e.g. 10m",
+			},
+			&cli.StringFlag{
+				Name:  "archive-section",
+				Usage: "Section archived posts are moved to, e.g. \"archive\" (by default they are kept in place as drafts flagged archived)",
+			},
+			&cli.StringFlag{
+				Name:  "git-backend",
+				Value: vcs.BackendAuto,
+				Usage: "How git is run: \"git\" runs the git command, \"go-git\" works without it, \"auto\" uses the command when installed",
+			},
 			&cli.BoolFlag{
 				Name:    "hugo-server",
 				Aliases: []string{"s"},
-				Usage:   "Start the local Hugo server alongside the editor",
+				Usage:   "Start the local Hugo server alongside the editor, serving the site under /site/",
+			},
+			&cli.StringFlag{
+				Name:  "hugo-port",
+				Value: "1313",
+				Usage: "Port the Hugo server listens on, behind the editor",
+			},
+			&cli.StringFlag{
+				Name:  "hugo-base-url",
+				Usage: "Base URL the site is reached at through the editor (defaults to http://localhost:<port>/site/)",
 			},
 		},
 		Action: runServer,
+		Commands: []*cli.Command{
+			{
+				Name:   "hash-password",
+				Usage:  "Read a password from stdin and print its hash for the users file",
+				Action: hashPassword,
+			},
+		},
 	}
 
 	if err := app.Run(os.Args); err != nil {
@@ -57,70 +143,75 @@ func main() {
 	}
 }
 
-// startHugoServer starts the local Hugo server in development mode
-func startHugoServer(contentDir string) {
-	log.Info().Msg("Starting Hugo server")
-
-	// Execute the hugo server command
-	cmd := exec.Command("hugo", "server", "-D")
-
-	// Set the command to run in the directory containing the content
-	if contentDir != "" {
-		// Extract the base directory (removing content/post from the path)
-		baseDir, _ := filepath.Abs(contentDir)
-		cmd.Dir = baseDir
+// hashPassword prints the bcrypt hash of the password read from stdin,
+// without echoing it when stdin is a terminal
+func hashPassword(c *cli.Context) error {
+	var password string
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		fmt.Fprint(os.Stderr, "Password: ")
+		input, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return fmt.Errorf("reading password: %w", err)
+		}
+		password = string(input)
 	} else {
-		// Default to current directory if no content dir specified
-		cmd.Dir = "."
+		input, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("reading password: %w", err)
+		}
+		password = strings.TrimRight(input, "\r\n")
 	}
-
-	log.Debug().Str("hugo_dir", cmd.Dir).Msg("Running Hugo server in directory")
-
-	// Redirect stdout and stderr to our logger
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to get stdout pipe for Hugo server")
-		return
+	if password == "" {
+		return fmt.Errorf("password is empty")
 	}
 
-	stderr, err := cmd.StderrPipe()
+	hash, err := auth.HashPassword(password)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to get stderr pipe for Hugo server")
-		return
+		return err
 	}
+	fmt.Println(hash)
+	return nil
+}
 
-	// Start the command
-	if err := cmd.Start(); err != nil {
-		log.Error().Err(err).Msg("Failed to start Hugo server")
-		return
-	}
+// configureTLS sets up the server to serve HTTPS when a certificate was
+// given or a self-signed one requested
+func configureTLS(c *cli.Context, server *web.Server) error {
+	certFile, keyFile := c.String("tls-cert"), c.String("tls-key")
 
-	// Log stdout
-	go func() {
-		scanner := bufio.NewScanner(stdout)
-		for scanner.Scan() {
-			log.Info().Str("source", "hugo").Msg(scanner.Text())
+	if c.Bool("tls-self-signed") {
+		if certFile == "" || keyFile == "" {
+			dir, err := certs.DefaultDir()
+			if err != nil {
+				return err
+			}
+			certFile = filepath.Join(dir, "cert.pem")
+			keyFile = filepath.Join(dir, "key.pem")
 		}
-	}()
-
-	// Log stderr
-	go func() {
-		scanner := bufio.NewScanner(stderr)
-		for scanner.Scan() {
-			log.Error().Str("source", "hugo").Msg(scanner.Text())
+		if err := certs.EnsureSelfSigned(certFile, keyFile); err != nil {
+			return err
 		}
-	}()
+	} else if (certFile == "") != (keyFile == "") {
+		return fmt.Errorf("--tls-cert and --tls-key have to be given together")
+	}
 
-	// Wait for the command to finish
-	go func() {
-		if err := cmd.Wait(); err != nil {
-			log.Error().Err(err).Msg("Hugo server exited with error")
-		} else {
-			log.Info().Msg("Hugo server exited")
+	if certFile == "" {
+		if c.String("http-redirect") != "" {
+			return fmt.Errorf("--http-redirect needs TLS to redirect to")
 		}
-	}()
+		return nil
+	}
 
-	log.Info().Msg("Hugo server started at http://localhost:1313/")
+	server.TLSCert = certFile
+	server.TLSKey = keyFile
+	server.RedirectAddr = c.String("http-redirect")
+	if server.RedirectAddr != "" && !strings.Contains(server.RedirectAddr, ":") {
+		server.RedirectAddr = ":" + server.RedirectAddr
+	}
+	// Browsers refuse to let users accept a self-signed certificate for a
+	// host that asked for HSTS
+	server.HSTS = !c.Bool("tls-self-signed")
+	return nil
 }
 
 func runServer(c *cli.Context) error {
@@ -130,19 +221,108 @@ func runServer(c *cli.Context) error {
 		log.Debug().Msg("Debug logging enabled")
 	}
 
-	// Start Hugo server if requested
-	if c.Bool("hugo-server") {
-		go startHugoServer(c.String("content-dir"))
-	}
-
 	// Create a new server
-	server, err := web.New(c.String("content-dir"), c.String("port"))
+	addr := c.String("listen")
+	if addr == "" {
+		addr = c.String("port")
+	}
+	server, err := web.New(c.String("content-dir"), addr)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to create server")
 		return err
 	}
+	server.ArchiveSection = c.String("archive-section")
+
+	// Use the requested git backend, web.New picks one by itself
+	if backend := c.String("git-backend"); backend != vcs.BackendAuto {
+		repo, err := vcs.OpenBackend(backend, server.SiteDir)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to open git repository")
+			return err
+		}
+		server.VCS = repo
+	}
+	switch strategy := vcs.Strategy(c.String("sync-strategy")); strategy {
+	case vcs.Rebase, vcs.Merge:
+		server.SyncStrategy = strategy
+	default:
+		return fmt.Errorf("unknown sync strategy %q, use rebase or merge", strategy)
+	}
+	server.SyncInterval = c.Duration("sync-interval")
+
+	// Set up TLS
+	if err := configureTLS(c, server); err != nil {
+		log.Error().Err(err).Msg("Failed to set up TLS")
+		return err
+	}
+
+	// Set up authentication
+	switch {
+	case c.String("users") != "" && c.String("auth-header") != "":
+		return fmt.Errorf("--users and --auth-header can't be combined")
+	case c.String("users") != "":
+		local, err := auth.NewLocal(c.String("users"))
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to load users")
+			return err
+		}
+		server.Auth = local
+	case c.String("auth-header") != "":
+		server.Auth = &auth.Proxy{
+			UserHeader:  c.String("auth-header"),
+			EmailHeader: c.String("auth-email-header"),
+			NameHeader:  c.String("auth-name-header"),
+		}
+	}
+
+	// Stop on SIGINT and SIGTERM, taking Hugo down with the editor
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Start Hugo server if requested, from the root of the site
+	if c.Bool("hugo-server") {
+		_, port, err := net.SplitHostPort(server.Addr)
+		if err != nil {
+			return fmt.Errorf("invalid listen address: %w", err)
+		}
+		baseURL := c.String("hugo-base-url")
+		if baseURL == "" {
+			scheme := "http"
+			if server.TLSCert != "" {
+				scheme = "https"
+			}
+			baseURL = scheme + "://localhost:" + port + web.SitePrefix
+		}
+		server.Hugo = hugo.New(hugo.Config{
+			Dir:            server.SiteDir,
+			Port:           c.String("hugo-port"),
+			BaseURL:        baseURL,
+			LiveReloadPort: port,
+		})
+		server.Hugo.Start(ctx)
+		defer server.Hugo.Stop()
+	}
 
 	// Start the server
 	log.Info().Msg("Starting server")
-	return server.Start()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.Start()
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	// Let in-flight requests and git commands finish
+	log.Info().Msg("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Error().Err(err).Msg("Failed to shut down gracefully")
+		return err
+	}
+	return <-errc
 }
@@ -0,0 +1,69 @@
+package posts
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+)
+
+// archivedKey is the front matter flag of posts archived in place
+const archivedKey = "archived"
+
+// RemoveFunc removes the file or directory at path, an absolute path
+type RemoveFunc func(path string) error
+
+// DeletePost removes a post from the content directory: its markdown file,
+// or the whole directory of a page bundle. os.RemoveAll is used when remove
+// is nil.
+func DeletePost(contentDir string, post Post, remove RemoveFunc) error {
+	log.Info().Str("path", post.Path).Msg("Deleting post")
+
+	if remove == nil {
+		remove = os.RemoveAll
+	}
+
+	path := filepath.Join(contentDir, post.Path)
+	if err := remove(path); err != nil {
+		log.Error().Err(err).Str("path", path).Msg("Failed to delete post")
+		return fmt.Errorf("deleting post: %w", err)
+	}
+	return nil
+}
+
+// ArchivePost takes a post out of the editor's listing while keeping it in
+// the site's history. With an archive section the post is moved there under
+// its current name; without one it is kept in place, marked as a draft and
+// flagged as archived in its front matter.
+func ArchivePost(contentDir string, post Post, section string, move MoveFunc) (Post, error) {
+	log.Info().Str("path", post.Path).Str("section", section).Msg("Archiving post")
+
+	if section != "" {
+		if err := os.MkdirAll(filepath.Join(contentDir, section), 0o755); err != nil {
+			return Post{}, fmt.Errorf("creating archive section: %w", err)
+		}
+		return RenamePost(contentDir, post, Rename{
+			Section: section,
+			Name:    post.Slug(),
+			Move:    move,
+		})
+	}
+
+	post.IsDraft = true
+	if post.FrontMatter == nil {
+		post.FrontMatter = NewFrontMatter(YAML)
+	}
+	if err := post.FrontMatter.Set(archivedKey, true); err != nil {
+		return Post{}, err
+	}
+	if err := SavePost(contentDir, post); err != nil {
+		return Post{}, err
+	}
+	return GetPost(contentDir, post.Path)
+}
+
+// Archived reports whether the post was archived in place
+func (p Post) Archived() bool {
+	return p.FrontMatter != nil && p.FrontMatter.Bool(archivedKey)
+}
@@ -0,0 +1,76 @@
+package posts
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+)
+
+// defaultFileMode is used for files that don't exist yet
+const defaultFileMode fs.FileMode = 0o644
+
+// WriteFileAtomic replaces the file at path with data. See WriteAtomic.
+func WriteFileAtomic(path string, data []byte) error {
+	return WriteAtomic(path, bytes.NewReader(data))
+}
+
+// WriteAtomic replaces the file at path with the contents of r. The data is
+// written to a temporary file in the same directory, synced to disk and
+// renamed over path, so readers (and git) see either the old file or the
+// complete new one, never a partial write. An existing file's mode is kept.
+func WriteAtomic(path string, r io.Reader) (err error) {
+	dir := filepath.Dir(path)
+
+	mode := defaultFileMode
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("checking %s: %w", path, err)
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		log.Error().Err(err).Str("path", path).Msg("Failed to create temporary file")
+		return fmt.Errorf("creating temporary file: %w", err)
+	}
+
+	// Clean up the temporary file unless it was renamed into place
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		return fmt.Errorf("setting mode of %s: %w", path, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("syncing %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("closing %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("replacing %s: %w", path, err)
+	}
+
+	// Make the rename itself durable
+	if d, err := os.Open(dir); err == nil {
+		if err := d.Sync(); err != nil {
+			log.Debug().Err(err).Str("dir", dir).Msg("Failed to sync directory")
+		}
+		d.Close()
+	}
+
+	return nil
+}
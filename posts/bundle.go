@@ -0,0 +1,108 @@
+package posts
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// bundleIndex is the content file of a Hugo leaf bundle
+const bundleIndex = "index.md"
+
+// Resource is a file stored in a page bundle next to its index.md
+type Resource struct {
+	Name string // path relative to the bundle directory
+	Size int64
+}
+
+// isBundle reports whether path is a leaf bundle directory
+func isBundle(path string) bool {
+	info, err := os.Stat(filepath.Join(path, bundleIndex))
+	return err == nil && !info.IsDir()
+}
+
+// ListResources returns the resource files of the bundle in dir, sorted by name
+func ListResources(dir string) ([]Resource, error) {
+	var resources []Resource
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		// Skip hidden files, such as temporary files of a write in progress
+		if strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if name == bundleIndex {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		resources = append(resources, Resource{
+			Name: filepath.ToSlash(name),
+			Size: info.Size(),
+		})
+		return nil
+	})
+	if err != nil {
+		log.Error().Err(err).Str("dir", dir).Msg("Failed to list bundle resources")
+		return nil, fmt.Errorf("listing resources: %w", err)
+	}
+
+	sort.Slice(resources, func(i, j int) bool {
+		return resources[i].Name < resources[j].Name
+	})
+	return resources, nil
+}
+
+// SaveResource writes a resource file into the bundle in dir, replacing any
+// existing file with the same name
+func SaveResource(dir, name string, data io.Reader) error {
+	log.Debug().Str("dir", dir).Str("name", name).Msg("Saving bundle resource")
+
+	if name == bundleIndex {
+		return fmt.Errorf("%s is the bundle's content file, not a resource", bundleIndex)
+	}
+
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("creating resource directory: %w", err)
+	}
+
+	if err := WriteAtomic(path, data); err != nil {
+		log.Error().Err(err).Str("path", path).Msg("Failed to write resource file")
+		return err
+	}
+	return nil
+}
+
+// DeleteResource removes a resource file from the bundle in dir
+func DeleteResource(dir, name string) error {
+	log.Debug().Str("dir", dir).Str("name", name).Msg("Deleting bundle resource")
+
+	if name == bundleIndex {
+		return fmt.Errorf("%s is the bundle's content file, not a resource", bundleIndex)
+	}
+
+	if err := os.Remove(filepath.Join(dir, name)); err != nil {
+		return fmt.Errorf("deleting resource: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package posts
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// defaultDateLayout is used for dates that weren't in the front matter
+// before, matching what Hugo's default archetype writes
+const defaultDateLayout = time.RFC3339
+
+// dateLayouts are the date formats accepted in front matter, tried in order
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 -0700 MST",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// dateField ties a front matter date key to the Post field holding it
+type dateField struct {
+	key   string
+	value *time.Time
+}
+
+// dateFields returns the Hugo date fields of the post
+func (p *Post) dateFields() []dateField {
+	return []dateField{
+		{"date", &p.Date},
+		{"publishDate", &p.PublishDate},
+		{"lastmod", &p.Lastmod},
+		{"expiryDate", &p.ExpiryDate},
+	}
+}
+
+// parseDate parses a front matter date in any of the layouts we accept,
+// returning the layout it was written in. Offsets are kept in the result.
+func parseDate(value string) (time.Time, string, error) {
+	value = strings.Trim(strings.TrimSpace(value), `"'`)
+
+	for _, layout := range dateLayouts {
+		date, err := time.Parse(layout, value)
+		if err == nil {
+			// RFC3339Nano also parses values without fractional seconds,
+			// which RFC3339 writes back identically
+			if layout == time.RFC3339Nano && date.Nanosecond() == 0 {
+				layout = time.RFC3339
+			}
+			return date, layout, nil
+		}
+	}
+	return time.Time{}, "", fmt.Errorf("invalid date format: %q", value)
+}
+
+// formatDate writes date in layout. Date-only layouts are widened when the
+// date has a time of day, so it isn't silently dropped.
+func formatDate(date time.Time, layout string) string {
+	hour, minute, second := date.Clock()
+	hasTime := hour != 0 || minute != 0 || second != 0 || date.Nanosecond() != 0
+	if hasTime && !strings.Contains(layout, "15") {
+		layout = defaultDateLayout
+	}
+	return date.Format(layout)
+}
@@ -0,0 +1,123 @@
+package posts
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	plus2 := time.FixedZone("", 2*60*60)
+	minus7 := time.FixedZone("", -7*60*60)
+
+	tests := []struct {
+		value  string
+		want   time.Time
+		layout string // empty when the value is rejected
+	}{
+		{"2024-01-02T03:04:05+02:00", time.Date(2024, 1, 2, 3, 4, 5, 0, plus2), time.RFC3339},
+		{"2024-01-02T03:04:05Z", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), time.RFC3339},
+		{"2024-01-02T03:04:05.5-07:00", time.Date(2024, 1, 2, 3, 4, 5, 5e8, minus7), time.RFC3339Nano},
+		{"2024-01-02T03:04:05", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), "2006-01-02T15:04:05"},
+		{"2024-01-02T03:04", time.Date(2024, 1, 2, 3, 4, 0, 0, time.UTC), "2006-01-02T15:04"},
+		{"2024-01-02 03:04:05+02:00", time.Date(2024, 1, 2, 3, 4, 5, 0, plus2), "2006-01-02 15:04:05Z07:00"},
+		{"2024-01-02 03:04:05 -0700", time.Date(2024, 1, 2, 3, 4, 5, 0, minus7), "2006-01-02 15:04:05 -0700"},
+		{"2024-01-02 03:04:05 -0700 MST", time.Date(2024, 1, 2, 3, 4, 5, 0, minus7), "2006-01-02 15:04:05 -0700 MST"},
+		{"2024-01-02 03:04:05", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), "2006-01-02 15:04:05"},
+		{"2024-01-02 03:04", time.Date(2024, 1, 2, 3, 4, 0, 0, time.UTC), "2006-01-02 15:04"},
+		{"2024-01-02", time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), "2006-01-02"},
+		{`"2024-01-02"`, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), "2006-01-02"},
+		{" '2024-01-02T03:04:05+02:00' ", time.Date(2024, 1, 2, 3, 4, 5, 0, plus2), time.RFC3339},
+		{"", time.Time{}, ""},
+		{"yesterday", time.Time{}, ""},
+		{"02/01/2024", time.Time{}, ""},
+		{"2024-13-02", time.Time{}, ""},
+	}
+	for _, test := range tests {
+		date, layout, err := parseDate(test.value)
+		if test.layout == "" {
+			if err == nil {
+				t.Errorf("parseDate(%q) = %v, want an error", test.value, date)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseDate(%q): %v", test.value, err)
+			continue
+		}
+		// Comparing the text checks the offset is kept too
+		if date.Format(time.RFC3339Nano) != test.want.Format(time.RFC3339Nano) || layout != test.layout {
+			t.Errorf("parseDate(%q) = %v in %q, want %v in %q", test.value, date, layout, test.want, test.layout)
+		}
+	}
+}
+
+func TestFormatDate(t *testing.T) {
+	plus2 := time.FixedZone("", 2*60*60)
+
+	tests := []struct {
+		date   time.Time
+		layout string
+		want   string
+	}{
+		{time.Date(2024, 5, 6, 7, 8, 9, 0, plus2), time.RFC3339, "2024-05-06T07:08:09+02:00"},
+		{time.Date(2024, 5, 6, 7, 8, 9, 0, plus2), "2006-01-02 15:04:05 -0700", "2024-05-06 07:08:09 +0200"},
+		{time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC), "2006-01-02", "2024-05-06"},
+		// A time of day isn't dropped by a date-only layout
+		{time.Date(2024, 5, 6, 7, 8, 9, 0, plus2), "2006-01-02", "2024-05-06T07:08:09+02:00"},
+	}
+	for _, test := range tests {
+		if got := formatDate(test.date, test.layout); got != test.want {
+			t.Errorf("formatDate(%v, %q) = %q, want %q", test.date, test.layout, got, test.want)
+		}
+	}
+}
+
+func TestDateRoundTrip(t *testing.T) {
+	plus2 := time.FixedZone("", 2*60*60)
+	changed := time.Date(2024, 5, 6, 7, 8, 9, 0, plus2)
+	day := time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		in   string // front matter line holding the date
+		date time.Time
+		want string
+	}{
+		{"offset", "date: 2024-01-02T03:04:05+02:00", changed, "date: 2024-05-06T07:08:09+02:00"},
+		{"utc", "date: 2024-01-02T03:04:05Z", changed.UTC(), "date: 2024-05-06T05:08:09Z"},
+		{"space separated", "date: 2024-01-02 03:04:05 -0700", changed, "date: 2024-05-06 07:08:09 +0200"},
+		{"quoted", `date: "2024-01-02T03:04:05+02:00"`, changed, `date: "2024-05-06T07:08:09+02:00"`},
+		{"date only", "date: 2024-01-02", day, "date: 2024-05-06"},
+		{"quoted date only", "date: '2024-01-02'", day, "date: '2024-05-06'"},
+		{"date only given a time", "date: 2024-01-02", changed, "date: 2024-05-06T07:08:09+02:00"},
+		{"new key", "lastmod: 2024-01-02", day, "lastmod: 2024-01-02\ndate: 2024-05-06T00:00:00Z"},
+		// The same instant in another zone isn't a change
+		{"same instant", "date: 2024-05-06T07:08:09+02:00", changed.UTC(), "date: 2024-05-06T07:08:09+02:00"},
+		{"toml offset", "date = 2024-01-02T03:04:05+02:00", changed, "date = 2024-05-06T07:08:09+02:00"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			delimiter := "---"
+			title := "title: Dates"
+			if strings.HasPrefix(test.in, "date =") {
+				delimiter, title = "+++", `title = "Dates"`
+			}
+			content := delimiter + "\n" + title + "\n" + test.in + "\n" + delimiter + "\nBody\n"
+
+			post, err := parsePost(content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			post.Apply(Edit{Date: &test.date})
+			got, err := post.Render()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := delimiter + "\n" + title + "\n" + test.want + "\n" + delimiter + "\nBody\n"
+			if got != want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
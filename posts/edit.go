@@ -0,0 +1,70 @@
+package posts
+
+import (
+	"slices"
+	"time"
+)
+
+// Edit holds the changes submitted from the editor for a post. Nil fields
+// keep the post's current value.
+type Edit struct {
+	Body  *string
+	Title *string
+	Date  *time.Time
+	Draft *bool
+	Tags  *[]string
+
+	Categories  *[]string
+	Description *string
+}
+
+// Apply merges the edit into the post
+func (p *Post) Apply(edit Edit) {
+	if edit.Body != nil {
+		p.Body = *edit.Body
+	}
+	if edit.Title != nil {
+		p.Title = *edit.Title
+	}
+	if edit.Date != nil {
+		p.Date = *edit.Date
+	}
+	if edit.Draft != nil {
+		p.IsDraft = *edit.Draft
+	}
+	if edit.Tags != nil {
+		p.Tags = *edit.Tags
+	}
+	if edit.Categories != nil {
+		p.Categories = *edit.Categories
+	}
+	if edit.Description != nil {
+		p.Description = *edit.Description
+	}
+}
+
+// Terms returns the distinct tags and categories used across posts, sorted,
+// for suggesting existing ones while editing
+func Terms(posts []Post) (tags, categories []string) {
+	for _, post := range posts {
+		tags = append(tags, post.Tags...)
+		categories = append(categories, post.Categories...)
+	}
+	slices.Sort(tags)
+	slices.Sort(categories)
+	return slices.Compact(tags), slices.Compact(categories)
+}
+
+// Render returns the file contents for the post: its front matter, with the
+// fields of the post merged in, followed by the body written verbatim
+func (p Post) Render() (string, error) {
+	fm, err := p.applyFrontMatter()
+	if err != nil {
+		return "", err
+	}
+	header, err := fm.render()
+	if err != nil {
+		return "", err
+	}
+	return header + p.Body, nil
+}
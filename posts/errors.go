@@ -0,0 +1,47 @@
+package posts
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/BurntSushi/toml"
+)
+
+// ParseError describes a post file whose front matter could not be parsed
+type ParseError struct {
+	File   string // path relative to the content directory
+	Line   int    // line within the file, 0 when unknown
+	Reason string
+}
+
+func (e *ParseError) Error() string {
+	switch {
+	case e.File != "" && e.Line > 0:
+		return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Reason)
+	case e.File != "":
+		return fmt.Sprintf("%s: %s", e.File, e.Reason)
+	case e.Line > 0:
+		return fmt.Sprintf("line %d: %s", e.Line, e.Reason)
+	default:
+		return e.Reason
+	}
+}
+
+var yamlErrorLine = regexp.MustCompile(`line (\d+)`)
+
+// errorLine extracts the line number from a front matter syntax error,
+// returning 0 when the error doesn't carry one
+func errorLine(err error) int {
+	var tomlErr toml.ParseError
+	if errors.As(err, &tomlErr) {
+		return tomlErr.Position.Line
+	}
+
+	if match := yamlErrorLine.FindStringSubmatch(err.Error()); match != nil {
+		line, _ := strconv.Atoi(match[1])
+		return line
+	}
+	return 0
+}
@@ -0,0 +1,375 @@
+package posts
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the syntax a post's front matter is written in
+type Format int
+
+const (
+	YAML Format = iota // delimited by ---
+	TOML               // delimited by +++
+	JSON               // a JSON object at the start of the file
+)
+
+const (
+	yamlDelimiter = "---"
+	tomlDelimiter = "+++"
+)
+
+// String returns the name of the format
+func (f Format) String() string {
+	switch f {
+	case TOML:
+		return "toml"
+	case JSON:
+		return "json"
+	default:
+		return "yaml"
+	}
+}
+
+// FrontMatter is a post's front matter kept as an ordered document, so keys
+// the editor doesn't know about (and the order they were written in) survive
+// a save untouched. Whatever the format on disk, the keys are held as a YAML
+// node tree and only converted back when the front matter is written.
+type FrontMatter struct {
+	format Format
+	raw    string     // front matter as read from disk, without delimiters
+	doc    *yaml.Node // mapping node holding the keys in file order
+	dirty  bool       // set once a key has been changed or removed
+}
+
+// NewFrontMatter returns an empty front matter document in the given format
+func NewFrontMatter(format Format) *FrontMatter {
+	return &FrontMatter{
+		format: format,
+		doc:    &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"},
+		dirty:  true,
+	}
+}
+
+// parseFrontMatter parses the text between the front matter delimiters
+func parseFrontMatter(raw string, format Format) (*FrontMatter, error) {
+	fm := &FrontMatter{format: format, raw: raw}
+
+	if format == TOML {
+		doc, err := parseTOML(raw)
+		if err != nil {
+			return nil, fmt.Errorf("parsing front matter: %w", err)
+		}
+		fm.doc = doc
+		return fm, nil
+	}
+
+	// JSON is a subset of YAML, so both go through the YAML parser
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(raw), &doc); err != nil {
+		return nil, fmt.Errorf("parsing front matter: %w", err)
+	}
+
+	switch {
+	case len(doc.Content) == 0:
+		// Empty front matter block
+		fm.doc = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	case doc.Content[0].Kind == yaml.MappingNode:
+		fm.doc = doc.Content[0]
+	default:
+		return nil, fmt.Errorf("front matter is not a mapping")
+	}
+
+	return fm, nil
+}
+
+// Format returns the syntax the front matter is written in
+func (fm *FrontMatter) Format() Format {
+	return fm.format
+}
+
+// splitFrontMatter separates the front matter block from the body of a post
+// and detects its format. ok is false when the content does not start with
+// a front matter block.
+func splitFrontMatter(content string) (front string, format Format, body string, ok bool) {
+	rest := strings.TrimLeft(strings.TrimPrefix(content, "\ufeff"), " \t\r\n")
+
+	if strings.HasPrefix(rest, "{") {
+		return splitJSONFrontMatter(rest)
+	}
+
+	line, rest, found := strings.Cut(rest, "\n")
+	if !found {
+		return "", YAML, content, false
+	}
+
+	var delimiter string
+	switch strings.TrimSpace(line) {
+	case yamlDelimiter:
+		delimiter, format = yamlDelimiter, YAML
+	case tomlDelimiter:
+		delimiter, format = tomlDelimiter, TOML
+	default:
+		return "", YAML, content, false
+	}
+
+	// Find the closing delimiter on a line of its own
+	offset := 0
+	for offset <= len(rest) {
+		line, _, _ := strings.Cut(rest[offset:], "\n")
+		if strings.TrimSpace(line) == delimiter {
+			body = rest[offset+len(line):]
+			body = strings.TrimPrefix(body, "\n")
+			return rest[:offset], format, body, true
+		}
+		next := strings.IndexByte(rest[offset:], '\n')
+		if next < 0 {
+			break
+		}
+		offset += next + 1
+	}
+
+	return "", YAML, content, false
+}
+
+// splitJSONFrontMatter splits off the JSON object a post starts with
+func splitJSONFrontMatter(content string) (front string, format Format, body string, ok bool) {
+	dec := json.NewDecoder(strings.NewReader(content))
+	var obj json.RawMessage
+	if err := dec.Decode(&obj); err != nil {
+		return "", JSON, content, false
+	}
+
+	end := int(dec.InputOffset())
+	body = strings.TrimPrefix(strings.TrimPrefix(content[end:], "\r"), "\n")
+	return content[:end], JSON, body, true
+}
+
+// Keys returns the front matter keys in the order they appear
+func (fm *FrontMatter) Keys() []string {
+	keys := make([]string, 0, len(fm.doc.Content)/2)
+	for i := 0; i+1 < len(fm.doc.Content); i += 2 {
+		keys = append(keys, fm.doc.Content[i].Value)
+	}
+	return keys
+}
+
+// lookup returns the index of the value node for key, or -1. Like Hugo,
+// keys are matched case insensitively when there is no exact match.
+func (fm *FrontMatter) lookup(key string) int {
+	for i := 0; i+1 < len(fm.doc.Content); i += 2 {
+		if fm.doc.Content[i].Value == key {
+			return i + 1
+		}
+	}
+	for i := 0; i+1 < len(fm.doc.Content); i += 2 {
+		if strings.EqualFold(fm.doc.Content[i].Value, key) {
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// Has reports whether the front matter contains key
+func (fm *FrontMatter) Has(key string) bool {
+	return fm.lookup(key) >= 0
+}
+
+// Line returns the line within the front matter where key is defined, or 0
+func (fm *FrontMatter) Line(key string) int {
+	i := fm.lookup(key)
+	if i < 0 {
+		return 0
+	}
+	return fm.doc.Content[i-1].Line
+}
+
+// String returns the text of a scalar value, or "" when the key is missing
+// or not a scalar
+func (fm *FrontMatter) String(key string) string {
+	i := fm.lookup(key)
+	if i < 0 || fm.doc.Content[i].Kind != yaml.ScalarNode {
+		return ""
+	}
+	return fm.doc.Content[i].Value
+}
+
+// Strings returns a list value. Besides YAML sequences it accepts the
+// comma separated `tags: a, b` form older posts use.
+func (fm *FrontMatter) Strings(key string) []string {
+	i := fm.lookup(key)
+	if i < 0 {
+		return nil
+	}
+
+	var values []string
+	switch node := fm.doc.Content[i]; node.Kind {
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			if item.Kind == yaml.ScalarNode && item.Value != "" {
+				values = append(values, item.Value)
+			}
+		}
+	case yaml.ScalarNode:
+		value := strings.Trim(node.Value, "[]")
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+	}
+	return values
+}
+
+// Bool returns a boolean value, false when the key is missing or not a
+// boolean. Like YAML, any capitalisation of true counts.
+func (fm *FrontMatter) Bool(key string) bool {
+	i := fm.lookup(key)
+	if i < 0 {
+		return false
+	}
+	var value bool
+	if err := fm.doc.Content[i].Decode(&value); err != nil {
+		return false
+	}
+	return value
+}
+
+// Set replaces the value of key, appending the key if it doesn't exist yet
+func (fm *FrontMatter) Set(key string, value any) error {
+	node := &yaml.Node{}
+	if err := node.Encode(value); err != nil {
+		return fmt.Errorf("encoding %s: %w", key, err)
+	}
+	fm.setNode(key, node)
+	return nil
+}
+
+// setValue replaces the text of a scalar value, keeping its tag and
+// quoting, so a quoted date stays quoted. Missing keys are added as a plain
+// scalar with the given tag.
+func (fm *FrontMatter) setValue(key, value, tag string) {
+	i := fm.lookup(key)
+	if i < 0 || fm.doc.Content[i].Kind != yaml.ScalarNode {
+		fm.setNode(key, &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value})
+		return
+	}
+
+	fm.dirty = true
+	fm.doc.Content[i].Value = value
+}
+
+func (fm *FrontMatter) setNode(key string, node *yaml.Node) {
+	fm.dirty = true
+
+	i := fm.lookup(key)
+	if i < 0 {
+		fm.doc.Content = append(fm.doc.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+			node)
+		return
+	}
+
+	// Keep quoting and comments of the value being replaced
+	old := fm.doc.Content[i]
+	if old.Kind == yaml.ScalarNode && node.Kind == yaml.ScalarNode && old.Tag == node.Tag &&
+		(old.Style == yaml.DoubleQuotedStyle || old.Style == yaml.SingleQuotedStyle) {
+		node.Style = old.Style
+	}
+	node.LineComment = old.LineComment
+	node.HeadComment = old.HeadComment
+	node.FootComment = old.FootComment
+	fm.doc.Content[i] = node
+}
+
+// Delete removes key from the front matter
+func (fm *FrontMatter) Delete(key string) {
+	i := fm.lookup(key)
+	if i < 0 {
+		return
+	}
+	fm.dirty = true
+	fm.doc.Content = append(fm.doc.Content[:i-1], fm.doc.Content[i+1:]...)
+}
+
+// encode returns the front matter text without delimiters. Unchanged front
+// matter is returned exactly as it was read.
+func (fm *FrontMatter) encode() (string, error) {
+	if !fm.dirty {
+		return fm.raw, nil
+	}
+
+	switch fm.format {
+	case TOML:
+		return encodeTOML(fm.doc)
+	case JSON:
+		return encodeJSON(fm.doc)
+	}
+
+	if len(fm.doc.Content) == 0 {
+		return "", nil
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(fm.indent())
+	if err := enc.Encode(fm.doc); err != nil {
+		return "", fmt.Errorf("encoding front matter: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return "", fmt.Errorf("encoding front matter: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// indent returns how far nested blocks are indented in the YAML front
+// matter as read, so re-encoding it leaves untouched blocks as they were.
+// It is 2 when nothing is nested.
+func (fm *FrontMatter) indent() int {
+	parent := -1 // column of the key opening the block the line may be in
+	for _, line := range strings.Split(fm.raw, "\n") {
+		text := strings.TrimLeft(line, " ")
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		column := len(line) - len(text)
+		if parent >= 0 && column > parent {
+			return min(column-parent, 9)
+		}
+
+		// Keys of a sequence item start after its dash
+		if rest, ok := strings.CutPrefix(text, "- "); ok {
+			column += len(text) - len(strings.TrimLeft(rest, " "))
+			text = strings.TrimLeft(rest, " ")
+		}
+		parent = -1
+		if strings.HasSuffix(strings.TrimRight(text, " \r"), ":") {
+			parent = column
+		}
+	}
+	return 2
+}
+
+// render returns the front matter block including its delimiters
+func (fm *FrontMatter) render() (string, error) {
+	text, err := fm.encode()
+	if err != nil {
+		return "", err
+	}
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+
+	switch fm.format {
+	case TOML:
+		return tomlDelimiter + "\n" + text + tomlDelimiter + "\n", nil
+	case JSON:
+		return text, nil
+	default:
+		return yamlDelimiter + "\n" + text + yamlDelimiter + "\n", nil
+	}
+}
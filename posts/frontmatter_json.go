@@ -0,0 +1,83 @@
+package posts
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// encodeJSON writes a YAML node tree out as an indented JSON object,
+// keeping the key order
+func encodeJSON(doc *yaml.Node) (string, error) {
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, doc); err != nil {
+		return "", fmt.Errorf("encoding front matter: %w", err)
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, buf.Bytes(), "", "  "); err != nil {
+		return "", fmt.Errorf("encoding front matter: %w", err)
+	}
+	return out.String(), nil
+}
+
+func writeJSON(buf *bytes.Buffer, node *yaml.Node) error {
+	node = resolveAlias(node)
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		buf.WriteByte('{')
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			key, err := json.Marshal(node.Content[i].Value)
+			if err != nil {
+				return err
+			}
+			buf.Write(key)
+			buf.WriteByte(':')
+			if err := writeJSON(buf, node.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+		return nil
+	case yaml.SequenceNode:
+		buf.WriteByte('[')
+		for i, item := range node.Content {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSON(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	}
+
+	switch node.ShortTag() {
+	case "!!int", "!!float":
+		if json.Valid([]byte(node.Value)) {
+			buf.WriteString(node.Value)
+			return nil
+		}
+	case "!!bool":
+		buf.WriteString(strings.ToLower(node.Value))
+		return nil
+	case "!!null":
+		buf.WriteString("null")
+		return nil
+	}
+
+	value, err := json.Marshal(node.Value)
+	if err != nil {
+		return err
+	}
+	buf.Write(value)
+	return nil
+}
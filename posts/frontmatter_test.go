@@ -0,0 +1,243 @@
+package posts
+
+import "testing"
+
+func TestFrontMatterRoundTrip(t *testing.T) {
+	title := "New title"
+	draft := true
+	tags := []string{"go", "hugo"}
+	description := "Added"
+
+	tests := []struct {
+		name string
+		in   string
+		edit Edit
+		want string // in when empty
+	}{
+		{
+			name: "yaml unchanged",
+			in: `---
+# Written by hand
+title: Hello
+custom: keep me # why
+params:
+    author: Jane
+    list:
+        - a
+        - b
+tags: [go, web]
+draft: True
+---
+Body
+`,
+		},
+		{
+			name: "yaml edited",
+			in: `---
+# Written by hand
+title: Hello
+custom: keep me # why
+params:
+    author: Jane
+    list:
+        - a
+        - b
+tags: [go, web]
+draft: false
+weight: 3
+---
+Body
+`,
+			edit: Edit{Title: &title, Draft: &draft, Tags: &tags},
+			want: `---
+# Written by hand
+title: New title
+custom: keep me # why
+params:
+    author: Jane
+    list:
+        - a
+        - b
+tags:
+    - go
+    - hugo
+draft: true
+weight: 3
+---
+Body
+`,
+		},
+		{
+			name: "yaml two space indent",
+			in: `---
+title: "Hello"
+params:
+  nested:
+    deep: 1
+---
+Body
+`,
+			edit: Edit{Title: &title},
+			want: `---
+title: "New title"
+params:
+  nested:
+    deep: 1
+---
+Body
+`,
+		},
+		{
+			name: "yaml new key appended",
+			in: `---
+zebra: 1
+title: Hello
+apple: 2
+---
+Body
+`,
+			edit: Edit{Description: &description},
+			want: `---
+zebra: 1
+title: Hello
+apple: 2
+description: Added
+---
+Body
+`,
+		},
+		{
+			name: "yaml draft unchanged",
+			in: `---
+title: Hello
+draft: TRUE
+unknown:
+    - {a: 1}
+---
+Body
+`,
+			edit: Edit{Draft: &draft},
+		},
+		{
+			name: "toml unchanged",
+			in: `+++
+title = "Hello"
+custom = 'literal'
+date = 2024-01-02T03:04:05+02:00
+
+[params]
+author = "Jane"
++++
+Body
+`,
+		},
+		{
+			name: "toml edited",
+			in: `+++
+title = "Hello"
+custom = "keep"
+draft = false
+date = 2024-01-02T03:04:05+02:00
+tags = ["go", "web"]
+
+[params]
+author = "Jane"
+list = [1, 2]
+
+[[resources]]
+src = "a.jpg"
+
+[[resources]]
+src = "b.jpg"
++++
+Body
+`,
+			edit: Edit{Title: &title, Draft: &draft, Tags: &tags},
+			want: `+++
+title = "New title"
+custom = "keep"
+draft = true
+date = 2024-01-02T03:04:05+02:00
+tags = ["go", "hugo"]
+
+[params]
+author = "Jane"
+list = [1, 2]
+
+[[resources]]
+src = "a.jpg"
+
+[[resources]]
+src = "b.jpg"
++++
+Body
+`,
+		},
+		{
+			name: "json unchanged",
+			in: `{"title": "Hello", "custom": {"nested": [1, 2]}}
+Body
+`,
+		},
+		{
+			name: "json edited",
+			in: `{
+  "title": "Hello",
+  "custom": {"nested": [1, 2]},
+  "draft": false,
+  "tags": ["go"]
+}
+Body
+`,
+			edit: Edit{Title: &title, Draft: &draft, Tags: &tags},
+			want: `{
+  "title": "New title",
+  "custom": {
+    "nested": [
+      1,
+      2
+    ]
+  },
+  "draft": true,
+  "tags": [
+    "go",
+    "hugo"
+  ]
+}
+Body
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			post, err := parsePost(test.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			post.Apply(test.edit)
+			got, err := post.Render()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := test.want
+			if want == "" {
+				want = test.in
+			}
+			if got != want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, want)
+			}
+
+			// What was written reads back the same
+			again, err := parsePost(got)
+			if err != nil {
+				t.Fatalf("parsing the written post: %v", err)
+			}
+			if again.Title != post.Title || again.IsDraft != post.IsDraft || again.Body != post.Body {
+				t.Errorf("read back %q, draft %v, body %q; want %q, draft %v, body %q",
+					again.Title, again.IsDraft, again.Body, post.Title, post.IsDraft, post.Body)
+			}
+		})
+	}
+}
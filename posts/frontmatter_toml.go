@@ -0,0 +1,293 @@
+package posts
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+var bareTOMLKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// parseTOML decodes TOML front matter into a YAML node tree, keeping the
+// keys in the order they were defined
+func parseTOML(raw string) (*yaml.Node, error) {
+	var data map[string]any
+	md, err := toml.Decode(raw, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Remember where each key first appeared so tables keep their order
+	order := make(map[string]int)
+	for i, key := range md.Keys() {
+		if _, ok := order[key.String()]; !ok {
+			order[key.String()] = i
+		}
+	}
+
+	doc := tomlNode(data, nil, order)
+
+	// Record the line of each top level key for error reporting
+	lines := strings.Split(raw, "\n")
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		key := doc.Content[i]
+		for n, line := range lines {
+			name, _, found := strings.Cut(strings.TrimSpace(line), "=")
+			if found && strings.Trim(strings.TrimSpace(name), `"'`) == key.Value {
+				key.Line = n + 1
+				break
+			}
+		}
+	}
+
+	return doc, nil
+}
+
+// tomlNode converts a decoded TOML value into a YAML node
+func tomlNode(value any, path toml.Key, order map[string]int) *yaml.Node {
+	switch v := value.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		position := func(key string) int {
+			if i, ok := order[append(path[:len(path):len(path)], key).String()]; ok {
+				return i
+			}
+			return len(order)
+		}
+		sort.SliceStable(keys, func(i, j int) bool {
+			if position(keys[i]) != position(keys[j]) {
+				return position(keys[i]) < position(keys[j])
+			}
+			return keys[i] < keys[j]
+		})
+
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, key := range keys {
+			node.Content = append(node.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+				tomlNode(v[key], append(path[:len(path):len(path)], key), order))
+		}
+		return node
+	case []map[string]any:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range v {
+			node.Content = append(node.Content, tomlNode(item, path, order))
+		}
+		return node
+	case []any:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range v {
+			node.Content = append(node.Content, tomlNode(item, path, order))
+		}
+		return node
+	case string:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v}
+	case int64:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.FormatInt(v, 10)}
+	case float64:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: strconv.FormatFloat(v, 'g', -1, 64)}
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(v)}
+	case time.Time:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!timestamp", Value: tomlTime(v)}
+	default:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: fmt.Sprint(v)}
+	}
+}
+
+// tomlTime formats a decoded TOML datetime the way it was written, using
+// the pseudo locations the decoder assigns to local dates and times
+func tomlTime(t time.Time) string {
+	switch t.Location().String() {
+	case "date-local":
+		return t.Format("2006-01-02")
+	case "datetime-local":
+		return t.Format("2006-01-02T15:04:05.999999999")
+	case "time-local":
+		return t.Format("15:04:05.999999999")
+	default:
+		return t.Format(time.RFC3339Nano)
+	}
+}
+
+// encodeTOML writes a YAML node tree out as TOML
+func encodeTOML(doc *yaml.Node) (string, error) {
+	var buf strings.Builder
+	if err := writeTOMLTable(&buf, doc, nil); err != nil {
+		return "", fmt.Errorf("encoding front matter: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// writeTOMLTable writes the keys of a table. Plain keys come first since
+// TOML requires them before any sub-table header.
+func writeTOMLTable(buf *strings.Builder, table *yaml.Node, path []string) error {
+	for i := 0; i+1 < len(table.Content); i += 2 {
+		key, value := table.Content[i].Value, resolveAlias(table.Content[i+1])
+		if isTOMLTable(value) || isTOMLTableArray(value) || value.ShortTag() == "!!null" {
+			continue
+		}
+		text, err := tomlValue(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		fmt.Fprintf(buf, "%s = %s\n", tomlKey(key), text)
+	}
+
+	for i := 0; i+1 < len(table.Content); i += 2 {
+		key, value := table.Content[i].Value, resolveAlias(table.Content[i+1])
+		header := append(path[:len(path):len(path)], key)
+
+		switch {
+		case isTOMLTable(value):
+			if buf.Len() > 0 {
+				buf.WriteString("\n")
+			}
+			fmt.Fprintf(buf, "[%s]\n", tomlPath(header))
+			if err := writeTOMLTable(buf, value, header); err != nil {
+				return err
+			}
+		case isTOMLTableArray(value):
+			for _, item := range value.Content {
+				if buf.Len() > 0 {
+					buf.WriteString("\n")
+				}
+				fmt.Fprintf(buf, "[[%s]]\n", tomlPath(header))
+				if err := writeTOMLTable(buf, resolveAlias(item), header); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func isTOMLTable(node *yaml.Node) bool {
+	return node.Kind == yaml.MappingNode
+}
+
+func isTOMLTableArray(node *yaml.Node) bool {
+	if node.Kind != yaml.SequenceNode || len(node.Content) == 0 {
+		return false
+	}
+	for _, item := range node.Content {
+		if resolveAlias(item).Kind != yaml.MappingNode {
+			return false
+		}
+	}
+	return true
+}
+
+// tomlValue formats a single value, using inline tables for mappings
+// nested in arrays
+func tomlValue(node *yaml.Node) (string, error) {
+	node = resolveAlias(node)
+
+	switch node.Kind {
+	case yaml.SequenceNode:
+		items := make([]string, 0, len(node.Content))
+		for _, item := range node.Content {
+			text, err := tomlValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, text)
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	case yaml.MappingNode:
+		items := make([]string, 0, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			text, err := tomlValue(node.Content[i+1])
+			if err != nil {
+				return "", err
+			}
+			items = append(items, tomlKey(node.Content[i].Value)+" = "+text)
+		}
+		return "{ " + strings.Join(items, ", ") + " }", nil
+	}
+
+	switch node.ShortTag() {
+	case "!!int", "!!timestamp":
+		return node.Value, nil
+	case "!!bool":
+		return strings.ToLower(node.Value), nil
+	case "!!float":
+		switch node.Value {
+		case ".inf", ".Inf", "+.inf":
+			return "inf", nil
+		case "-.inf", "-.Inf":
+			return "-inf", nil
+		case ".nan", ".NaN":
+			return "nan", nil
+		}
+		return node.Value, nil
+	case "!!null":
+		return "", fmt.Errorf("TOML has no null value")
+	default:
+		return tomlString(node.Value), nil
+	}
+}
+
+func tomlKey(key string) string {
+	if bareTOMLKey.MatchString(key) {
+		return key
+	}
+	return tomlString(key)
+}
+
+func tomlPath(path []string) string {
+	keys := make([]string, len(path))
+	for i, key := range path {
+		keys[i] = tomlKey(key)
+	}
+	return strings.Join(keys, ".")
+}
+
+// tomlString quotes s as a TOML basic string
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
@@ -1,146 +1,165 @@
 package posts
 
 import (
-	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/ionrock/hugs/slug"
 	"github.com/rs/zerolog/log"
 )
 
 type Post struct {
 	Title    string
 	Date     time.Time
-	Content  string
+	Content  string // raw file contents, including the front matter
+	Revision string // hash of Content, to detect changes made since reading
+	Body     string // markdown following the front matter
 	IsDraft  bool
 	Tags     []string
 	Filename string
+
+	Categories  []string
+	Description string
+
+	// Hugo's other date fields, zero when not set
+	PublishDate time.Time
+	Lastmod     time.Time
+	ExpiryDate  time.Time
+
+	// Path identifies the post relative to the content directory: the
+	// markdown file for single file posts and the directory for page
+	// bundles, e.g. "post/hello.md" or "post/my-trip"
+	Path      string
+	Section   string // directory the post lives in, e.g. "post"
+	IsBundle  bool
+	Resources []Resource // files shipped alongside a bundle's index.md
+
+	// FrontMatter holds every front matter key, including the ones not
+	// mapped onto the fields above
+	FrontMatter *FrontMatter
 }
 
-// ListPosts returns all posts in the content/post directory, ordered by date (newest first)
-func ListPosts(contentDir string) ([]Post, error) {
+// ListPosts returns all posts in a section of the content directory, ordered
+// by date (newest first). Files that can't be parsed don't stop the listing;
+// they are reported as problems alongside the posts that could be read.
+func ListPosts(contentDir, section string) ([]Post, []ParseError, error) {
 	var posts []Post
+	var problems []ParseError
 
-	log.Debug().Str("dir", contentDir).Msg("Listing posts")
+	dir := filepath.Join(contentDir, section)
+	log.Debug().Str("dir", dir).Msg("Listing posts")
 
-	files, err := os.ReadDir(contentDir)
+	files, err := os.ReadDir(dir)
 	if err != nil {
-		log.Error().Err(err).Str("dir", contentDir).Msg("Failed to read posts directory")
-		return nil, fmt.Errorf("reading posts directory: %w", err)
+		log.Error().Err(err).Str("dir", dir).Msg("Failed to read posts directory")
+		return nil, nil, fmt.Errorf("reading posts directory: %w", err)
 	}
 
 	for _, file := range files {
-		if !file.IsDir() && strings.HasSuffix(file.Name(), ".md") {
-			post, err := ReadPost(filepath.Join(contentDir, file.Name()))
-			if err != nil {
-				log.Error().Err(err).Str("file", file.Name()).Msg("Failed to read post")
-				return nil, fmt.Errorf("reading post %s: %w", file.Name(), err)
+		if file.IsDir() {
+			// Only leaf bundles are posts, other directories are skipped
+			if !isBundle(filepath.Join(dir, file.Name())) {
+				continue
+			}
+		} else if !strings.HasSuffix(file.Name(), ".md") || file.Name() == sectionIndex {
+			continue
+		}
+
+		path := filepath.Join(section, file.Name())
+		post, err := GetPost(contentDir, path)
+		if err != nil {
+			log.Error().Err(err).Str("file", file.Name()).Msg("Failed to read post")
+
+			problem := ParseError{Reason: err.Error()}
+			var parseErr *ParseError
+			if errors.As(err, &parseErr) {
+				problem = *parseErr
+			}
+			problem.File = filepath.ToSlash(path)
+			if file.IsDir() {
+				problem.File = filepath.ToSlash(filepath.Join(path, bundleIndex))
 			}
-			posts = append(posts, post)
+			problems = append(problems, problem)
+			continue
 		}
+		posts = append(posts, post)
 	}
 
-	// Sort posts by date, newest first
-	sort.Slice(posts, func(i, j int) bool {
+	SortByDate(posts)
+
+	log.Debug().Int("count", len(posts)).Int("problems", len(problems)).Msg("Posts found and sorted by date")
+	return posts, problems, nil
+}
+
+// SortByDate sorts posts by date, newest first
+func SortByDate(posts []Post) {
+	sort.SliceStable(posts, func(i, j int) bool {
 		return posts[i].Date.After(posts[j].Date)
 	})
+}
+
+// GetPost reads the post at path, relative to the content directory
+func GetPost(contentDir, path string) (Post, error) {
+	post, err := ReadPost(filepath.Join(contentDir, path))
+	if err != nil {
+		return Post{}, err
+	}
 
-	log.Debug().Int("count", len(posts)).Msg("Posts found and sorted by date")
-	return posts, nil
+	post.Path = filepath.ToSlash(filepath.Clean(path))
+	post.Section = filepath.ToSlash(filepath.Dir(post.Path))
+	if post.Section == "." {
+		post.Section = ""
+	}
+	return post, nil
 }
 
-// ReadPost reads a post file and parses its front matter
+// ReadPost reads a post file and parses its front matter. path may also be
+// a leaf bundle directory, in which case its index.md is read and the other
+// files in the bundle are listed as resources.
 func ReadPost(path string) (Post, error) {
 	log.Debug().Str("path", path).Msg("Reading post")
 
-	content, err := os.ReadFile(path)
+	file := path
+	bundle := isBundle(path)
+	if bundle {
+		file = filepath.Join(path, bundleIndex)
+	}
+
+	content, err := os.ReadFile(file)
 	if err != nil {
-		log.Error().Err(err).Str("path", path).Msg("Failed to read post file")
+		log.Error().Err(err).Str("path", file).Msg("Failed to read post file")
 		return Post{}, fmt.Errorf("reading post: %w", err)
 	}
 
-	scanner := bufio.NewScanner(strings.NewReader(string(content)))
-	inFrontMatter := false
-	post := Post{
-		Filename: filepath.Base(path),
-		Content:  string(content),
+	post, err := parsePost(string(content))
+	if err != nil {
+		log.Error().Err(err).Str("path", file).Msg("Failed to parse post")
+		return Post{}, err
 	}
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		if line == "---" {
-			if !inFrontMatter {
-				inFrontMatter = true
-				continue
-			} else {
-				break
-			}
-		}
-		if inFrontMatter {
-			parts := strings.SplitN(line, ":", 2)
-			if len(parts) != 2 {
-				continue
-			}
-			key := strings.TrimSpace(parts[0])
-			value := strings.TrimSpace(parts[1])
-
-			switch key {
-			case "title":
-				// Remove quotes at the beginning and end of the title
-				post.Title = strings.Trim(value, "\"")
-			case "date":
-				dateformats := []string{
-					"2006-01-02",
-					"2006-01-02 15:04:05",
-					"2006-01-02 15:04",
-					"2006-01-02T15:04:05Z",
-				}
-				for _, format := range dateformats {
-					date, err := time.Parse(format, value)
-					if err != nil {
-						continue
-					}
-					post.Date = date
-					break
-				}
-				if post.Date.IsZero() {
-					log.Error().Str("value", value).Msg("Invalid date format")
-					return Post{}, fmt.Errorf("invalid date format: %v", err)
-				}
-			case "draft":
-				post.IsDraft = value == "true"
-			case "tags":
-				// Remove brackets if present
-				value = strings.Trim(value, "[]")
-				if value != "" {
-					// Split on commas and trim spaces
-					tags := strings.Split(value, ",")
-					for i, tag := range tags {
-						tags[i] = strings.TrimSpace(tag)
-					}
-					post.Tags = tags
-				}
-			}
+	post.Filename = filepath.Base(file)
+	post.Path = filepath.Base(path)
+	post.IsBundle = bundle
+
+	if bundle {
+		post.Resources, err = ListResources(path)
+		if err != nil {
+			return Post{}, err
 		}
 	}
 
-	if post.Title == "" {
-		log.Error().Str("path", path).Msg("Title not found in content")
-		return Post{}, fmt.Errorf("title not found in content")
-	}
-
 	// Create filename from title if not set
 	if post.Filename == "" {
 		log.Info().Str("title", post.Title).Msg("Creating filename from title")
-		slug := strings.ToLower(post.Title)
-		slug = strings.ReplaceAll(slug, " ", "-")
-		slug = strings.ReplaceAll(slug, "'", "")
-		slug = strings.ReplaceAll(slug, "\"", "")
-		post.Filename = fmt.Sprintf("%s.md", slug)
+		post.Filename = slug.Make(post.Title) + ".md"
 	} else {
 		log.Debug().Str("filename", post.Filename).Msg("Loaded post")
 	}
@@ -148,89 +167,247 @@ func ReadPost(path string) (Post, error) {
 	return post, nil
 }
 
-// CreateNewPost creates a new post with the given title
-func CreateNewPost(contentDir, title string) (Post, error) {
-	log.Info().Str("title", title).Str("dir", contentDir).Msg("Creating new post")
+// parsePost parses the front matter and body of a post's raw content.
+// Problems with the content are returned as a *ParseError.
+func parsePost(content string) (Post, error) {
+	post := Post{
+		Content:  content,
+		Revision: Revision(content),
+	}
+
+	front, format, body, ok := splitFrontMatter(content)
+	if !ok {
+		return Post{}, &ParseError{Line: 1, Reason: "front matter not found"}
+	}
+	post.Body = body
+
+	// Lines before the front matter text, to turn its line numbers into
+	// line numbers within the file
+	offset := strings.Count(content[:strings.Index(content, front)], "\n")
+
+	fm, err := parseFrontMatter(front, format)
+	if err != nil {
+		return Post{}, &ParseError{Line: offset + errorLine(err), Reason: err.Error()}
+	}
+	post.FrontMatter = fm
+
+	post.Title = fm.String("title")
+	for _, field := range post.dateFields() {
+		value := fm.String(field.key)
+		if value == "" {
+			continue
+		}
+		date, _, err := parseDate(value)
+		if err != nil {
+			log.Error().Str("key", field.key).Str("value", value).Msg("Invalid date format")
+			return Post{}, &ParseError{Line: offset + fm.Line(field.key), Reason: err.Error()}
+		}
+		*field.value = date
+	}
+	post.IsDraft = fm.Bool("draft")
+	post.Tags = fm.Strings("tags")
+	post.Categories = fm.Strings("categories")
+	post.Description = fm.String("description")
+
+	if post.Title == "" {
+		return Post{}, &ParseError{Line: max(offset, 1), Reason: "title not found in front matter"}
+	}
+
+	return post, nil
+}
+
+// Revision returns the revision of a post's raw contents. Comparing it with
+// the revision a post was edited at shows whether the file changed since.
+func Revision(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:16])
+}
 
-	now := time.Now()
+// FileRevision returns the revision of the file at path, or "" when the file
+// doesn't exist
+func FileRevision(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("reading post: %w", err)
+	}
+	return Revision(string(content)), nil
+}
+
+// applyFrontMatter merges the editable fields of the post into its front
+// matter. Keys are only rewritten when their value actually changed, so
+// everything else is written back exactly as it was read.
+func (p Post) applyFrontMatter() (*FrontMatter, error) {
+	fm := p.FrontMatter
+	if fm == nil {
+		fm = NewFrontMatter(YAML)
+	}
+
+	if fm.String("title") != p.Title {
+		if err := fm.Set("title", p.Title); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, field := range p.dateFields() {
+		if field.value.IsZero() {
+			continue
+		}
+
+		// Write changed dates in the layout the date was originally in
+		date, layout, err := parseDate(fm.String(field.key))
+		if err != nil {
+			layout = defaultDateLayout
+		}
+		if err != nil || !date.Equal(*field.value) {
+			fm.setValue(field.key, formatDate(*field.value, layout), "!!timestamp")
+		}
+	}
+
+	if fm.Bool("draft") != p.IsDraft {
+		if err := fm.Set("draft", p.IsDraft); err != nil {
+			return nil, err
+		}
+	}
+
+	lists := []struct {
+		key    string
+		values []string
+	}{
+		{"tags", p.Tags},
+		{"categories", p.Categories},
+	}
+	for _, list := range lists {
+		if slices.Equal(fm.Strings(list.key), list.values) {
+			continue
+		}
+		if len(list.values) == 0 {
+			fm.Delete(list.key)
+		} else if err := fm.Set(list.key, list.values); err != nil {
+			return nil, err
+		}
+	}
+
+	if fm.String("description") != p.Description {
+		if p.Description == "" {
+			fm.Delete("description")
+		} else if err := fm.Set("description", p.Description); err != nil {
+			return nil, err
+		}
+	}
+
+	return fm, nil
+}
+
+// CreateNewPost creates a new post with the given title in a section of the
+// content directory
+func CreateNewPost(contentDir, section, title string) (Post, error) {
+	log.Info().Str("title", title).Str("dir", contentDir).Str("section", section).Msg("Creating new post")
+
+	now := time.Now().Truncate(time.Second)
 	post := Post{
 		Title:   title,
 		Date:    now,
 		IsDraft: true,
 	}
 
-	// Create the filename from the title
-	slug := strings.ToLower(title)
-	slug = strings.ReplaceAll(slug, " ", "-")
-	slug = strings.ReplaceAll(slug, "'", "")
-	slug = strings.ReplaceAll(slug, "\"", "")
-	post.Filename = fmt.Sprintf("%s.md", slug)
+	// Create the filename from the title, numbering it when a post by that
+	// name (single file or bundle) exists already
+	dir := filepath.Join(contentDir, section)
+	base := slug.Make(title)
+	if base == "" {
+		base = "post"
+	}
+	name, err := slug.Unique(base, func(name string) bool {
+		return nameTaken(dir, name)
+	})
+	if err != nil {
+		log.Error().Err(err).Str("title", title).Msg("No free filename for post")
+		return Post{}, err
+	}
+	post.Filename = name + ".md"
+	post.Path = filepath.ToSlash(filepath.Join(section, post.Filename))
+	post.Section = section
 
-	// Create the file
-	path := filepath.Join(contentDir, post.Filename)
-	file, err := os.Create(path)
+	// Build the front matter
+	fm, err := post.applyFrontMatter()
+	if err != nil {
+		return Post{}, err
+	}
+	header, err := fm.render()
+	if err != nil {
+		return Post{}, err
+	}
+	post.FrontMatter = fm
+	post.Body = "\n"
+	post.Content = header + post.Body
+
+	// Claim the name before writing, so a post created concurrently under the
+	// same name is never overwritten
+	path := filepath.Join(contentDir, post.File())
+	claim, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, defaultFileMode)
 	if err != nil {
 		log.Error().Err(err).Str("path", path).Msg("Failed to create post file")
 		return Post{}, err
 	}
-	defer file.Close()
+	claim.Close()
 
-	// Write the front matter
-	fmt.Fprintf(file, "---\ntitle: %s\ndate: %s\ndraft: true\n---\n\n", title, now.Format("2006-01-02"))
+	// Write the file
+	if err := WriteFileAtomic(path, []byte(post.Content)); err != nil {
+		log.Error().Err(err).Str("path", path).Msg("Failed to write post file")
+		return Post{}, err
+	}
 
 	return post, nil
 }
 
-// SavePost saves a post to disk
+// nameTaken reports whether a post named name, either a markdown file or a
+// page bundle, exists in dir
+func nameTaken(dir, name string) bool {
+	_, fileErr := os.Lstat(filepath.Join(dir, name+".md"))
+	_, dirErr := os.Lstat(filepath.Join(dir, name))
+	return fileErr == nil || dirErr == nil
+}
+
+// SavePost saves a post to disk. Front matter keys other than the ones
+// backing the Post fields are written back unchanged.
 func SavePost(contentDir string, post Post) error {
 	log.Debug().Str("filename", post.Filename).Str("dir", contentDir).Bool("draft", post.IsDraft).Msg("Saving post")
 
-	path := filepath.Join(contentDir, post.Filename)
-	file, err := os.Create(path)
+	content, err := post.Render()
 	if err != nil {
-		log.Error().Err(err).Str("path", path).Msg("Failed to create post file")
+		log.Error().Err(err).Str("filename", post.Filename).Msg("Failed to render post")
 		return err
 	}
-	defer file.Close()
 
-	// Write the front matter
-	fmt.Fprintf(file, "---\ntitle: %s\ndate: %s\ndraft: %v\n---\n\n%s",
-		post.Title,
-		post.Date.Format("2006-01-02"),
-		post.IsDraft,
-		post.Content)
+	path := filepath.Join(contentDir, post.File())
+	if err := WriteFileAtomic(path, []byte(content)); err != nil {
+		log.Error().Err(err).Str("path", path).Msg("Failed to write post file")
+		return err
+	}
 
 	return nil
 }
 
-// Slug returns the base filename without the .md extension
+// Slug returns the base filename without the .md extension, or the
+// directory name for page bundles
 func (p Post) Slug() string {
+	if p.IsBundle {
+		return filepath.Base(p.Path)
+	}
 	return strings.TrimSuffix(p.Filename, ".md")
 }
 
-func NewPostFromMarkdown(content string) (string, error) {
-	log.Debug().Msg("Extracting title from markdown content")
-
-	scanner := bufio.NewScanner(strings.NewReader(content))
-	inFrontMatter := false
-	var title string
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		if line == "---" {
-			if !inFrontMatter {
-				inFrontMatter = true
-				continue
-			} else {
-				break
-			}
-		}
-		if inFrontMatter && strings.HasPrefix(line, "title:") {
-			title = strings.TrimSpace(strings.TrimPrefix(line, "title:"))
-			return title, nil
-		}
+// File returns the path of the post's markdown file relative to the content
+// directory
+func (p Post) File() string {
+	if p.IsBundle {
+		return filepath.Join(p.Path, bundleIndex)
 	}
-
-	log.Error().Msg("Title not found in markdown content")
-	return "", fmt.Errorf("title not found in content")
+	if p.Path != "" {
+		return p.Path
+	}
+	return p.Filename
 }
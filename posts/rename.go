@@ -0,0 +1,123 @@
+package posts
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ionrock/hugs/slug"
+	"github.com/rs/zerolog/log"
+)
+
+// MoveFunc moves the file or directory at from to to, both absolute paths
+type MoveFunc func(from, to string) error
+
+// Rename holds where a post is moved to by RenamePost
+type Rename struct {
+	Section string // section to move the post to
+	Name    string // new filename without .md, or bundle directory name
+
+	// Alias adds the post's current URL to its aliases, so Hugo redirects
+	// links to the old address
+	Alias bool
+
+	// Move performs the move, os.Rename when nil
+	Move MoveFunc
+}
+
+// RenamePost moves a post (its markdown file, or the whole directory of a
+// page bundle) to a new name and section of the content directory and
+// returns it as read from its new location. A slug set in the front matter
+// is updated when the name changes, as it would otherwise keep the old URL.
+func RenamePost(contentDir string, post Post, rename Rename) (Post, error) {
+	log.Info().Str("path", post.Path).Str("section", rename.Section).Str("name", rename.Name).Msg("Renaming post")
+
+	// The name isn't required to be a slug, so posts archived under their
+	// current name keep it; names typed by users are made slugs by the caller
+	if rename.Name == "" || strings.ContainsAny(rename.Name, "/\\\x00") || strings.HasPrefix(rename.Name, ".") {
+		return Post{}, fmt.Errorf("invalid post name %q", rename.Name)
+	}
+
+	path := filepath.ToSlash(filepath.Join(rename.Section, rename.Name))
+	if !post.IsBundle {
+		path += ".md"
+	}
+	if path == post.Path {
+		return post, nil
+	}
+
+	// A file and a bundle of the same name would share a URL, so neither
+	// may exist already
+	from := filepath.Join(contentDir, post.Path)
+	to := filepath.Join(contentDir, path)
+	if nameTaken(filepath.Join(contentDir, rename.Section), rename.Name) {
+		return Post{}, fmt.Errorf("%w: %s", slug.ErrExists, path)
+	}
+
+	oldURL := post.URL()
+
+	move := rename.Move
+	if move == nil {
+		move = os.Rename
+	}
+	if err := move(from, to); err != nil {
+		log.Error().Err(err).Str("from", from).Str("to", to).Msg("Failed to move post")
+		return Post{}, fmt.Errorf("moving post: %w", err)
+	}
+
+	renamed, err := GetPost(contentDir, path)
+	if err != nil {
+		return Post{}, err
+	}
+
+	// Keep the front matter in step with the new location
+	fm := renamed.FrontMatter
+	changed := false
+	if fm.Has("slug") && rename.Name != post.Slug() {
+		if err := fm.Set("slug", rename.Name); err != nil {
+			return Post{}, err
+		}
+		changed = true
+	}
+	if rename.Alias && renamed.URL() != oldURL {
+		aliases := fm.Strings("aliases")
+		if !slices.Contains(aliases, oldURL) {
+			if err := fm.Set("aliases", append(aliases, oldURL)); err != nil {
+				return Post{}, err
+			}
+			changed = true
+		}
+	}
+	if !changed {
+		return renamed, nil
+	}
+
+	if err := SavePost(contentDir, renamed); err != nil {
+		return Post{}, err
+	}
+	return GetPost(contentDir, path)
+}
+
+// URL returns the path Hugo publishes the post at with its default
+// permalinks: the url front matter key when set, otherwise the section
+// followed by the slug key or the post's name
+func (p Post) URL() string {
+	if p.FrontMatter != nil {
+		if url := p.FrontMatter.String("url"); url != "" {
+			return url
+		}
+	}
+
+	name := p.Slug()
+	if p.FrontMatter != nil && p.FrontMatter.String("slug") != "" {
+		name = p.FrontMatter.String("slug")
+	}
+
+	parts := []string{p.Section, name}
+	if p.Section == "" {
+		parts = parts[1:]
+	}
+	return "/" + strings.ToLower(strings.Join(parts, "/")) + "/"
+}
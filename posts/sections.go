@@ -0,0 +1,95 @@
+package posts
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// sectionIndex is the content file of a Hugo branch bundle, which holds a
+// section's own front matter rather than a post
+const sectionIndex = "_index.md"
+
+// Section is a Hugo content section, a directory of posts below content/
+type Section struct {
+	Path  string // relative to the content directory, e.g. "post" or "projects/go"
+	Title string // title from the section's _index.md, or the directory name
+}
+
+// ListSections returns the content sections under contentDir. Top level
+// directories are always sections; nested directories are sections when
+// they hold posts or an _index.md. Page bundles are posts, not sections.
+func ListSections(contentDir string) ([]Section, error) {
+	var sections []Section
+
+	log.Debug().Str("dir", contentDir).Msg("Listing sections")
+
+	err := filepath.WalkDir(contentDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() || path == contentDir {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") || isBundle(path) {
+			return filepath.SkipDir
+		}
+
+		rel, err := filepath.Rel(contentDir, path)
+		if err != nil {
+			return err
+		}
+
+		topLevel := !strings.ContainsRune(rel, filepath.Separator)
+		if !topLevel && !isSection(path) {
+			return nil
+		}
+
+		sections = append(sections, Section{
+			Path:  filepath.ToSlash(rel),
+			Title: sectionTitle(path),
+		})
+		return nil
+	})
+	if err != nil {
+		log.Error().Err(err).Str("dir", contentDir).Msg("Failed to list sections")
+		return nil, fmt.Errorf("listing sections: %w", err)
+	}
+
+	return sections, nil
+}
+
+// isSection reports whether dir has an _index.md or contains any posts
+func isSection(dir string) bool {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+
+	for _, file := range files {
+		switch {
+		case file.Name() == sectionIndex:
+			return true
+		case file.IsDir() && isBundle(filepath.Join(dir, file.Name())):
+			return true
+		case !file.IsDir() && strings.HasSuffix(file.Name(), ".md"):
+			return true
+		}
+	}
+	return false
+}
+
+// sectionTitle returns the title of the section in dir
+func sectionTitle(dir string) string {
+	index := filepath.Join(dir, sectionIndex)
+	if _, err := os.Stat(index); err == nil {
+		if post, err := ReadPost(index); err == nil {
+			return post.Title
+		}
+	}
+	return filepath.Base(dir)
+}
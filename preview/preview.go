@@ -0,0 +1,42 @@
+// Package preview renders post markdown to HTML the way Hugo does by
+// default, for previewing posts without running Hugo.
+package preview
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+)
+
+// markdown mirrors Hugo's default markup.goldmark configuration: the GitHub
+// flavoured extensions plus footnotes, definition lists and typographer,
+// and automatic heading IDs. Raw HTML is left out, as Hugo only renders it
+// when markup.goldmark.renderer.unsafe is set.
+var markdown = goldmark.New(
+	goldmark.WithExtensions(
+		extension.Table,
+		extension.Strikethrough,
+		extension.Linkify,
+		extension.TaskList,
+		extension.Footnote,
+		extension.DefinitionList,
+		extension.Typographer,
+	),
+	goldmark.WithParserOptions(
+		parser.WithAutoHeadingID(),
+		parser.WithAttribute(),
+	),
+)
+
+// Render converts markdown to HTML. Raw HTML in the markdown is replaced
+// by a comment, as Hugo does by default. Shortcodes aren't expanded.
+func Render(source string) (string, error) {
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(source), &buf); err != nil {
+		return "", fmt.Errorf("rendering markdown: %w", err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,103 @@
+// Package slug turns post titles into file and URL friendly names.
+package slug
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxLength is the longest slug Make returns, in runes
+const MaxLength = 80
+
+// maxSuffix bounds the numbered suffixes Unique tries
+const maxSuffix = 1000
+
+// ErrExists is returned when no free name could be found for a slug
+var ErrExists = errors.New("a post with this name already exists")
+
+// transliterations spell out letters that don't decompose into an ASCII
+// base letter and accents
+var transliterations = map[rune]string{
+	'ß': "ss",
+	'æ': "ae",
+	'œ': "oe",
+	'ø': "o",
+	'ł': "l",
+	'đ': "d",
+	'ð': "d",
+	'þ': "th",
+	'ı': "i",
+	'&': " and ",
+}
+
+// Make returns the slug for title: lowercase letters and digits separated by
+// single hyphens. Accented letters are reduced to their base letter, other
+// punctuation is dropped and long titles are cut at a word boundary.
+func Make(title string) string {
+	var b strings.Builder
+	hyphen := false
+
+	// Decomposing first splits accented letters into base letter and marks
+	for _, r := range norm.NFKD.String(strings.ToLower(title)) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+
+		text, ok := transliterations[r]
+		if !ok {
+			text = string(r)
+		}
+
+		for _, r := range text {
+			switch {
+			case unicode.IsLetter(r) || unicode.IsDigit(r):
+				if hyphen && b.Len() > 0 {
+					b.WriteByte('-')
+				}
+				hyphen = false
+				b.WriteRune(r)
+			case r == '\'' || r == '’':
+				// Apostrophes join words: "don't" becomes "dont"
+			default:
+				hyphen = true
+			}
+		}
+	}
+
+	return truncate(b.String(), MaxLength)
+}
+
+// truncate cuts slug to at most limit runes, preferring to cut at a hyphen
+func truncate(slug string, limit int) string {
+	runes := []rune(slug)
+	if len(runes) <= limit {
+		return slug
+	}
+
+	cut := string(runes[:limit])
+	if i := strings.LastIndexByte(cut, '-'); i > 0 && runes[limit] != '-' {
+		cut = cut[:i]
+	}
+	return strings.TrimSuffix(cut, "-")
+}
+
+// Unique returns slug when taken reports it as free, otherwise the first of
+// slug-2, slug-3, ... that is. ErrExists is returned when none is.
+func Unique(slug string, taken func(string) bool) (string, error) {
+	if !taken(slug) {
+		return slug, nil
+	}
+
+	for i := 2; i <= maxSuffix; i++ {
+		suffix := fmt.Sprintf("-%d", i)
+		candidate := truncate(slug, MaxLength-len(suffix)) + suffix
+		if !taken(candidate) {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrExists, slug)
+}
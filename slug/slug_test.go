@@ -0,0 +1,93 @@
+package slug
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"Hello World", "hello-world"},
+		{"C++ & Go: Part 2?", "c-and-go-part-2"},
+		{"  --Leading and trailing--  ", "leading-and-trailing"},
+		{"Don't stop", "dont-stop"},
+		{"It’s here", "its-here"},
+		{"Crème brûlée à la carte", "creme-brulee-a-la-carte"},
+		{"Straße in Łódź", "strasse-in-lodz"},
+		{"Ærø Œuvre", "aero-oeuvre"},
+		{"Ｆｕｌｌ　ｗｉｄｔｈ", "full-width"},
+		{"Привет, мир", "привет-мир"},
+		{"日本語のタイトル", "日本語のタイトル"},
+		{"Go 1.22 release", "go-1-22-release"},
+		{"", ""},
+		{"!!! ??? ...", ""},
+		{"🎉", ""},
+	}
+	for _, test := range tests {
+		if got := Make(test.title); got != test.want {
+			t.Errorf("Make(%q) = %q, want %q", test.title, got, test.want)
+		}
+	}
+}
+
+func TestMakeTruncates(t *testing.T) {
+	title := strings.Repeat("word ", 30)
+	got := Make(title)
+	if utf8.RuneCountInString(got) > MaxLength {
+		t.Errorf("Make returned %d runes, want at most %d", utf8.RuneCountInString(got), MaxLength)
+	}
+	// Cut at a word boundary, without a trailing hyphen
+	if !strings.HasSuffix(got, "-word") {
+		t.Errorf("Make(%q) = %q, want it cut after a whole word", title, got)
+	}
+
+	long := strings.Repeat("x", MaxLength+10)
+	if got := Make(long); got != strings.Repeat("x", MaxLength) {
+		t.Errorf("Make of a single long word = %q, want it cut at %d runes", got, MaxLength)
+	}
+}
+
+func TestUnique(t *testing.T) {
+	taken := func(names ...string) func(string) bool {
+		return func(name string) bool { return slices.Contains(names, name) }
+	}
+
+	tests := []struct {
+		slug  string
+		taken []string
+		want  string
+	}{
+		{"post", nil, "post"},
+		{"post", []string{"post"}, "post-2"},
+		{"post", []string{"post", "post-2"}, "post-3"},
+		{"post", []string{"post", "post-3"}, "post-2"},
+		{"post-2", []string{"post-2"}, "post-2-2"},
+	}
+	for _, test := range tests {
+		got, err := Unique(test.slug, taken(test.taken...))
+		if err != nil || got != test.want {
+			t.Errorf("Unique(%q) with %q taken = %q, %v; want %q", test.slug, test.taken, got, err, test.want)
+		}
+	}
+
+	// Suffixes keep long slugs within MaxLength
+	long := strings.Repeat("a", MaxLength)
+	got, err := Unique(long, taken(long))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) > MaxLength || !strings.HasSuffix(got, "-2") {
+		t.Errorf("Unique of a long slug = %q, want it cut to fit -2", got)
+	}
+
+	// Running out of names
+	if _, err := Unique("post", func(string) bool { return true }); !errors.Is(err, ErrExists) {
+		t.Errorf("Unique with every name taken = %v, want ErrExists", err)
+	}
+}
@@ -8,6 +8,10 @@ package templates
 import "github.com/a-h/templ"
 import templruntime "github.com/a-h/templ/runtime"
 
+import "github.com/ionrock/hugs/auth"
+import "github.com/ionrock/hugs/csrf"
+import "github.com/ionrock/hugs/flash"
+
 func Base() templ.Component {
 	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
 		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
@@ -29,15 +33,187 @@ func Base() templ.Component {
 			templ_7745c5c3_Var1 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<!doctype html><html lang=\"en\"><head><meta charset=\"UTF-8\"><meta name=\"viewport\" content=\"width=device-width, initial-scale=1.0\"><script src=\"https://unpkg.com/tiny-markdown-editor/dist/tiny-mde.min.js\"></script><link rel=\"stylesheet\" type=\"text/css\" href=\"https://unpkg.com/tiny-markdown-editor/dist/tiny-mde.min.css\"><title>Hugo Blog Editor</title><style>\n        :root {\n            --background: #ffffff;\n            --foreground: #171717;\n            --muted: #f5f5f5;\n            --muted-foreground: #737373;\n            --border: #e5e5e5;\n            --input: #e5e5e5;\n            --primary: #171717;\n            --primary-foreground: #ffffff;\n            --ring: #171717;\n        }\n\n        * {\n            box-sizing: border-box;\n        }\n\n        body {\n            font-family: -apple-system, BlinkMacSystemFont, \"Segoe UI\", Roboto, Helvetica, Arial, sans-serif;\n            max-width: 800px;\n            margin: 0 auto;\n            padding: 20px;\n            line-height: 1.6;\n            color: var(--foreground);\n            background: var(--background);\n        }\n\n        .header {\n            display: flex;\n            justify-content: space-between;\n            align-items: center;\n            margin-bottom: 24px;\n            padding-bottom: 16px;\n            border-bottom: 1px solid var(--border);\n        }\n\n        .button {\n            background: var(--primary);\n            color: var(--primary-foreground);\n            padding: 8px 16px;\n            border-radius: 6px;\n            text-decoration: none;\n            font-size: 14px;\n            font-weight: 500;\n            transition: opacity 0.2s;\n        }\n\n        .button:hover {\n            opacity: 0.9;\n        }\n        \n        .button.disabled {\n            background: var(--muted);\n            color: var(--muted-foreground);\n            cursor: not-allowed;\n            pointer-events: none;\n        }\n\n        .post-list {\n            list-style: none;\n            padding: 0;\n        }\n\n        .post-item {\n            padding: 16px;\n            margin-bottom: 12px;\n            transition: all 0.2s ease;\n        }\n\n        .post-item:hover {\n            box-shadow: 0 1px 3px rgba(0, 0, 0, 0.05);\n            border-color: var(--ring);\n        }\n\n        .post-title {\n            margin: 0;\n            font-size: 1.1em;\n            font-weight: 500;\n        }\n\n        .post-meta {\n            color: var(--muted-foreground);\n            font-size: 0.85em;\n            margin-top: 6px;\n        }\n\n        .draft-badge {\n            background: var(--muted);\n            color: var(--foreground);\n            padding: 2px 8px;\n            border-radius: 4px;\n            font-size: 0.75em;\n            font-weight: 500;\n            margin-left: 8px;\n        }\n\n        .form-group {\n            margin-bottom: 24px;\n        }\n\n        label {\n            display: block;\n            margin-bottom: 8px;\n            font-size: 14px;\n            font-weight: 500;\n        }\n\n        input[type=\"text\"] {\n            width: 100%;\n            padding: 10px 12px;\n            font-size: 15px;\n            border: 1px solid var(--input);\n            border-radius: 6px;\n            outline: none;\n            transition: border-color 0.2s, box-shadow 0.2s;\n        }\n\n        input[type=\"text\"]:focus {\n            border-color: var(--ring);\n            box-shadow: 0 0 0 1px var(--ring);\n        }\n\n        textarea {\n            width: 100%;\n            height: 600px;\n            padding: 12px;\n            font-size: 15px;\n            font-family: monospace;\n            border: 1px solid var(--input);\n            border-radius: 6px;\n            outline: none;\n            transition: border-color 0.2s, box-shadow 0.2s;\n        }\n\n        textarea:focus {\n            border-color: var(--ring);\n            box-shadow: 0 0 0 1px var(--ring);\n        }\n\n        .checkbox-group {\n            margin: 24px 0;\n            display: flex;\n            align-items: center;\n            gap: 8px;\n        }\n\n        button {\n            background: var(--primary);\n            color: var(--primary-foreground);\n            border: none;\n            padding: 10px 20px;\n            border-radius: 6px;\n            cursor: pointer;\n            font-size: 14px;\n            font-weight: 500;\n            transition: opacity 0.2s;\n        }\n\n        button:hover {\n            opacity: 0.9;\n        }\n\n        .back-link {\n            display: inline-block;\n            margin-bottom: 24px;\n            color: var(--foreground);\n            text-decoration: none;\n            font-size: 14px;\n            font-weight: 500;\n        }\n\n        .back-link:hover {\n            text-decoration: underline;\n        }\n    </style></head><body>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<!doctype html><html lang=\"en\"><head><meta charset=\"UTF-8\"><meta name=\"viewport\" content=\"width=device-width, initial-scale=1.0\"><script src=\"https://unpkg.com/tiny-markdown-editor/dist/tiny-mde.min.js\"></script><link rel=\"stylesheet\" type=\"text/css\" href=\"https://unpkg.com/tiny-markdown-editor/dist/tiny-mde.min.css\"><meta name=\"csrf-token\" content=\"")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		var templ_7745c5c3_Var2 string
+		templ_7745c5c3_Var2, templ_7745c5c3_Err = templ.JoinStringErrs(csrf.Token(ctx))
+		if templ_7745c5c3_Err != nil {
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/base.templ`, Line: 19, Col: 52}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var2))
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "\"><title>Hugo Blog Editor</title><style>\n        :root {\n            --background: #ffffff;\n            --foreground: #171717;\n            --muted: #f5f5f5;\n            --muted-foreground: #737373;\n            --border: #e5e5e5;\n            --input: #e5e5e5;\n            --primary: #171717;\n            --primary-foreground: #ffffff;\n            --ring: #171717;\n        }\n\n        * {\n            box-sizing: border-box;\n        }\n\n        body {\n            font-family: -apple-system, BlinkMacSystemFont, \"Segoe UI\", Roboto, Helvetica, Arial, sans-serif;\n            max-width: 800px;\n            margin: 0 auto;\n            padding: 20px;\n            line-height: 1.6;\n            color: var(--foreground);\n            background: var(--background);\n        }\n\n        .header {\n            display: flex;\n            justify-content: space-between;\n            align-items: center;\n            margin-bottom: 24px;\n            padding-bottom: 16px;\n            border-bottom: 1px solid var(--border);\n        }\n\n        .button {\n            background: var(--primary);\n            color: var(--primary-foreground);\n            padding: 8px 16px;\n            border-radius: 6px;\n            text-decoration: none;\n            font-size: 14px;\n            font-weight: 500;\n            transition: opacity 0.2s;\n        }\n\n        .button:hover {\n            opacity: 0.9;\n        }\n        \n        .button.disabled {\n            background: var(--muted);\n            color: var(--muted-foreground);\n            cursor: not-allowed;\n            pointer-events: none;\n        }\n\n        .post-list {\n            list-style: none;\n            padding: 0;\n        }\n\n        .post-item {\n            padding: 16px;\n            margin-bottom: 12px;\n            transition: all 0.2s ease;\n        }\n\n        .post-item:hover {\n            box-shadow: 0 1px 3px rgba(0, 0, 0, 0.05);\n            border-color: var(--ring);\n        }\n\n        .post-title {\n            margin: 0;\n            font-size: 1.1em;\n            font-weight: 500;\n        }\n\n        .post-meta {\n            color: var(--muted-foreground);\n            font-size: 0.85em;\n            margin-top: 6px;\n        }\n\n        .draft-badge {\n            background: var(--muted);\n            color: var(--foreground);\n            padding: 2px 8px;\n            border-radius: 4px;\n            font-size: 0.75em;\n            font-weight: 500;\n            margin-left: 8px;\n        }\n\n        .section-nav {\n            display: flex;\n            flex-wrap: wrap;\n            gap: 8px;\n            margin-bottom: 16px;\n        }\n\n        .section-nav a {\n            color: var(--muted-foreground);\n            padding: 4px 10px;\n            border-radius: 6px;\n            text-decoration: none;\n            font-size: 14px;\n        }\n\n        .section-nav a.active {\n            background: var(--muted);\n            color: var(--foreground);\n        }\n\n        .section-badge {\n            color: var(--muted-foreground);\n            margin-left: 8px;\n        }\n\n        .problem {\n            border-left: 3px solid #dc2626;\n            padding: 8px 12px;\n            margin-bottom: 16px;\n        }\n\n        .diff {\n            background: var(--muted);\n            padding: 12px;\n            border-radius: 6px;\n            overflow-x: auto;\n            font-size: 13px;\n        }\n\n        .diff span {\n            display: block;\n            white-space: pre;\n        }\n\n        .diff-insert {\n            background: #dcfce7;\n        }\n\n        .diff-delete {\n            background: #fee2e2;\n        }\n\n        .flash {\n            margin-bottom: 24px;\n            padding: 12px 16px;\n            border-radius: 6px;\n            white-space: pre-wrap;\n            overflow-wrap: anywhere;\n        }\n\n        .flash-success {\n            background: #dcfce7;\n            border: 1px solid #86efac;\n        }\n\n        .flash-error {\n            background: #fee2e2;\n            border: 1px solid #fca5a5;\n        }\n\n        .change-list {\n            list-style: none;\n            padding: 0;\n        }\n\n        .change-list li {\n            padding: 4px 0;\n            border-bottom: 1px solid var(--border);\n        }\n\n        .change-list code {\n            display: inline-block;\n            min-width: 2.5em;\n        }\n\n        .change-list .conflicted {\n            background: #fee2e2;\n        }\n\n        .status-list {\n            display: grid;\n            grid-template-columns: max-content 1fr;\n            gap: 4px 16px;\n        }\n\n        .status-list dd {\n            margin: 0;\n        }\n\n        .form-group {\n            margin-bottom: 24px;\n        }\n\n        label {\n            display: block;\n            margin-bottom: 8px;\n            font-size: 14px;\n            font-weight: 500;\n        }\n\n        input[type=\"text\"],\n        input[type=\"password\"],\n        input[type=\"datetime-local\"],\n        select {\n            width: 100%;\n            padding: 10px 12px;\n            font-size: 15px;\n            border: 1px solid var(--input);\n            border-radius: 6px;\n            outline: none;\n            transition: border-color 0.2s, box-shadow 0.2s;\n        }\n\n        input[type=\"text\"]:focus,\n        input[type=\"password\"]:focus,\n        input[type=\"datetime-local\"]:focus {\n            border-color: var(--ring);\n            box-shadow: 0 0 0 1px var(--ring);\n        }\n\n        textarea {\n            width: 100%;\n            height: 600px;\n            padding: 12px;\n            font-size: 15px;\n            font-family: monospace;\n            border: 1px solid var(--input);\n            border-radius: 6px;\n            outline: none;\n            transition: border-color 0.2s, box-shadow 0.2s;\n        }\n\n        textarea:focus {\n            border-color: var(--ring);\n            box-shadow: 0 0 0 1px var(--ring);\n        }\n\n        .checkbox-group {\n            margin: 24px 0;\n            display: flex;\n            align-items: center;\n            gap: 8px;\n        }\n\n        button {\n            background: var(--primary);\n            color: var(--primary-foreground);\n            border: none;\n            padding: 10px 20px;\n            border-radius: 6px;\n            cursor: pointer;\n            font-size: 14px;\n            font-weight: 500;\n            transition: opacity 0.2s;\n        }\n\n        button:hover {\n            opacity: 0.9;\n        }\n\n        .back-link {\n            display: inline-block;\n            margin-bottom: 24px;\n            color: var(--foreground);\n            text-decoration: none;\n            font-size: 14px;\n            font-weight: 500;\n        }\n\n        .back-link:hover {\n            text-decoration: underline;\n        }\n\n        .resource-list {\n            list-style: none;\n            padding: 0;\n        }\n\n        .resource-item {\n            display: flex;\n            align-items: center;\n            gap: 12px;\n            padding: 8px 0;\n            border-bottom: 1px solid var(--border);\n        }\n\n        .inline-form {\n            display: inline;\n            margin-left: auto;\n        }\n\n        .editor-layout {\n            display: grid;\n            grid-template-columns: 1fr 1fr;\n            gap: 16px;\n            position: relative;\n            left: 50%;\n            width: min(1400px, calc(100vw - 40px));\n            transform: translateX(-50%);\n        }\n\n        @media (max-width: 900px) {\n            .editor-layout {\n                grid-template-columns: 1fr;\n            }\n        }\n\n        .preview {\n            height: 500px;\n            overflow-y: scroll;\n            border: 1px solid #c0c0c0;\n            padding: 0 16px;\n        }\n\n        .preview img {\n            max-width: 100%;\n        }\n\n        .preview table {\n            border-collapse: collapse;\n        }\n\n        .preview th,\n        .preview td {\n            border: 1px solid var(--border);\n            padding: 4px 8px;\n        }\n\n        .user-bar {\n            display: flex;\n            justify-content: flex-end;\n            align-items: center;\n            gap: 12px;\n            font-size: 14px;\n            color: var(--muted-foreground);\n        }\n\n        .link-button {\n            background: none;\n            color: var(--muted-foreground);\n            padding: 0;\n            text-decoration: underline;\n        }\n    </style></head><body>")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		if user, ok := auth.FromContext(ctx); ok {
+			templ_7745c5c3_Err = userBar(user).Render(ctx, templ_7745c5c3_Buffer)
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		}
+		if message, ok := flash.FromContext(ctx); ok {
+			var templ_7745c5c3_Var3 = []any{"flash", "flash-" + string(message.Kind)}
+			templ_7745c5c3_Err = templ.RenderCSSItems(ctx, templ_7745c5c3_Buffer, templ_7745c5c3_Var3...)
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "<div class=\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var4 string
+			templ_7745c5c3_Var4, templ_7745c5c3_Err = templ.JoinStringErrs(templ.CSSClasses(templ_7745c5c3_Var3).String())
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/base.templ`, Line: 1, Col: 0}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var4))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 4, "\" role=\"status\">")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var5 string
+			templ_7745c5c3_Var5, templ_7745c5c3_Err = templ.JoinStringErrs(message.Text)
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/base.templ`, Line: 377, Col: 88}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var5))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 5, "</div>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		}
 		templ_7745c5c3_Err = templ_7745c5c3_Var1.Render(ctx, templ_7745c5c3_Buffer)
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "</body></html>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 6, "</body></html>")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		return nil
+	})
+}
+
+func userBar(user auth.User) templ.Component {
+	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
+			return templ_7745c5c3_CtxErr
+		}
+		templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+		if !templ_7745c5c3_IsBuffer {
+			defer func() {
+				templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+				if templ_7745c5c3_Err == nil {
+					templ_7745c5c3_Err = templ_7745c5c3_BufErr
+				}
+			}()
+		}
+		ctx = templ.InitializeContext(ctx)
+		templ_7745c5c3_Var6 := templ.GetChildren(ctx)
+		if templ_7745c5c3_Var6 == nil {
+			templ_7745c5c3_Var6 = templ.NopComponent
+		}
+		ctx = templ.ClearChildren(ctx)
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 7, "<div class=\"user-bar\"><span>Signed in as ")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		var templ_7745c5c3_Var7 string
+		templ_7745c5c3_Var7, templ_7745c5c3_Err = templ.JoinStringErrs(user.Username)
+		if templ_7745c5c3_Err != nil {
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/base.templ`, Line: 386, Col: 36}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var7))
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 8, "</span> ")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		if user.Session {
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 9, "<form method=\"POST\" action=\"/logout\" class=\"inline-form\">")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = csrfField().Render(ctx, templ_7745c5c3_Buffer)
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 10, "<button type=\"submit\" class=\"link-button\">Log out</button></form>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 11, "</div>")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		return nil
+	})
+}
+
+// csrfField sends the request's CSRF token along with a form
+func csrfField() templ.Component {
+	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
+			return templ_7745c5c3_CtxErr
+		}
+		templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+		if !templ_7745c5c3_IsBuffer {
+			defer func() {
+				templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+				if templ_7745c5c3_Err == nil {
+					templ_7745c5c3_Err = templ_7745c5c3_BufErr
+				}
+			}()
+		}
+		ctx = templ.InitializeContext(ctx)
+		templ_7745c5c3_Var8 := templ.GetChildren(ctx)
+		if templ_7745c5c3_Var8 == nil {
+			templ_7745c5c3_Var8 = templ.NopComponent
+		}
+		ctx = templ.ClearChildren(ctx)
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 12, "<input type=\"hidden\" name=\"")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		var templ_7745c5c3_Var9 string
+		templ_7745c5c3_Var9, templ_7745c5c3_Err = templ.JoinStringErrs(csrf.FieldName)
+		if templ_7745c5c3_Err != nil {
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/base.templ`, Line: 398, Col: 43}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var9))
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 13, "\" value=\"")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		var templ_7745c5c3_Var10 string
+		templ_7745c5c3_Var10, templ_7745c5c3_Err = templ.JoinStringErrs(csrf.Token(ctx))
+		if templ_7745c5c3_Err != nil {
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/base.templ`, Line: 398, Col: 69}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var10))
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 14, "\">")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
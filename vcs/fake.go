@@ -0,0 +1,358 @@
+package vcs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakeCommit is a commit recorded by Fake
+type FakeCommit struct {
+	Message string
+	Author  string
+	Paths   []string // staged paths, relative to the root
+}
+
+// Fake is an in-memory Repository for tests. Moves and removals are applied
+// to the files on disk, as callers expect, but nothing is recorded in a
+// real repository. Like git, adding, moving or removing a path that is
+// neither tracked nor on disk fails. Errors set in Errors, keyed by method
+// name such as "Commit", are returned by that method instead. Integrate
+// stops on the conflicts set in Conflicts, each resolved by adding its path.
+type Fake struct {
+	Dir string // root of the working tree
+
+	mu        sync.Mutex
+	Branch    string
+	Upstream  string
+	Ahead     int
+	Behind    int
+	Operation Strategy
+	Conflicts map[string]Conflict
+	Tracked   map[string]bool // files in the index, relative to the root
+	Staged    []string
+	Commits   []FakeCommit
+	Pushes    int
+	Pulls     int
+	Fetches   int
+	Errors    map[string]error
+}
+
+// NewFake returns a fake repository of dir on branch main, tracking
+// origin/main. The files in dir are tracked.
+func NewFake(dir string) *Fake {
+	f := &Fake{
+		Dir:       dir,
+		Branch:    "main",
+		Upstream:  "origin/main",
+		Conflicts: make(map[string]Conflict),
+		Tracked:   make(map[string]bool),
+		Errors:    make(map[string]error),
+	}
+	f.track(dir)
+	return f
+}
+
+func (f *Fake) Root() string {
+	return f.Dir
+}
+
+// fail returns the error configured for method
+func (f *Fake) fail(method string) error {
+	return f.Errors[method]
+}
+
+// rel returns path relative to the root
+func (f *Fake) rel(path string) string {
+	if !filepath.IsAbs(path) {
+		return filepath.ToSlash(filepath.Clean(path))
+	}
+	rel, err := filepath.Rel(f.Dir, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// abs returns path relative to the working directory of the fake
+func (f *Fake) abs(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(f.Dir, path)
+}
+
+// track adds the files at or under path to the index
+func (f *Fake) track(path string) {
+	filepath.WalkDir(f.abs(path), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() {
+			f.Tracked[f.rel(path)] = true
+		}
+		return nil
+	})
+}
+
+// untrack removes the files at or under path from the index, returning
+// whether there were any
+func (f *Fake) untrack(path string) bool {
+	path = f.rel(path)
+	found := false
+	for file := range f.Tracked {
+		if file == path || strings.HasPrefix(file, path+"/") {
+			delete(f.Tracked, file)
+			found = true
+		}
+	}
+	return found
+}
+
+// isTracked reports whether there are files at or under path in the index
+func (f *Fake) isTracked(path string) bool {
+	path = f.rel(path)
+	for file := range f.Tracked {
+		if file == path || strings.HasPrefix(file, path+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// pathspecError is what git says about a path it knows nothing of
+func (f *Fake) pathspecError(command, path string) error {
+	return &Error{
+		Args:   []string{command, "--", path},
+		Stderr: fmt.Sprintf("fatal: pathspec '%s' did not match any files", path),
+		Err:    errors.New("exit status 128"),
+	}
+}
+
+func (f *Fake) stage(paths ...string) {
+	for _, path := range paths {
+		path := f.rel(path)
+		if f.Operation != "" {
+			delete(f.Conflicts, path)
+		}
+		if !slices.Contains(f.Staged, path) {
+			f.Staged = append(f.Staged, path)
+		}
+	}
+}
+
+func (f *Fake) Add(ctx context.Context, paths ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fail("Add"); err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if _, err := os.Lstat(f.abs(path)); err == nil {
+			f.track(path)
+		} else if !f.untrack(path) {
+			return f.pathspecError("add", path)
+		}
+	}
+	f.stage(paths...)
+	return nil
+}
+
+func (f *Fake) Move(ctx context.Context, from, to string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fail("Move"); err != nil {
+		return err
+	}
+	if !f.isTracked(from) {
+		return &Error{
+			Args:   []string{"mv", "--", from, to},
+			Stderr: fmt.Sprintf("fatal: not under version control, source=%s, destination=%s", f.rel(from), f.rel(to)),
+			Err:    errors.New("exit status 128"),
+		}
+	}
+	if err := os.Rename(f.abs(from), f.abs(to)); err != nil {
+		return err
+	}
+	f.untrack(from)
+	f.track(to)
+	f.stage(from, to)
+	return nil
+}
+
+func (f *Fake) Remove(ctx context.Context, path string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fail("Remove"); err != nil {
+		return err
+	}
+	if !f.isTracked(path) {
+		return f.pathspecError("rm", path)
+	}
+	if err := os.RemoveAll(f.abs(path)); err != nil {
+		return err
+	}
+	f.untrack(path)
+	f.stage(path)
+	return nil
+}
+
+func (f *Fake) Commit(ctx context.Context, message, author string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fail("Commit"); err != nil {
+		return err
+	}
+	f.Commits = append(f.Commits, FakeCommit{Message: message, Author: author, Paths: f.Staged})
+	f.Staged = nil
+	f.Ahead++
+	return nil
+}
+
+func (f *Fake) Status(ctx context.Context) (Status, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fail("Status"); err != nil {
+		return Status{}, err
+	}
+
+	status := Status{
+		Branch:    f.Branch,
+		Upstream:  f.Upstream,
+		Ahead:     f.Ahead,
+		Behind:    f.Behind,
+		Operation: f.Operation,
+	}
+	if f.Operation != "" {
+		for path := range f.Conflicts {
+			status.Changes = append(status.Changes, Change{Path: path, Staged: "U", Unstaged: "U"})
+		}
+	}
+	for _, path := range f.Staged {
+		status.Changes = append(status.Changes, Change{Path: path, Staged: "M", Unstaged: "."})
+	}
+	return status, nil
+}
+
+func (f *Fake) Push(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fail("Push"); err != nil {
+		return err
+	}
+	f.Pushes++
+	f.Ahead = 0
+	return nil
+}
+
+func (f *Fake) Pull(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fail("Pull"); err != nil {
+		return err
+	}
+	f.Pulls++
+	f.Behind = 0
+	return nil
+}
+
+func (f *Fake) Fetch(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fail("Fetch"); err != nil {
+		return err
+	}
+	f.Fetches++
+	return nil
+}
+
+func (f *Fake) Integrate(ctx context.Context, strategy Strategy) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fail("Integrate"); err != nil {
+		return err
+	}
+	if len(f.Conflicts) > 0 {
+		f.Operation = strategy
+		return ErrConflict
+	}
+	f.Behind = 0
+	return nil
+}
+
+func (f *Fake) Conflict(ctx context.Context, path string) (Conflict, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fail("Conflict"); err != nil {
+		return Conflict{}, err
+	}
+	conflict, ok := f.Conflicts[f.rel(path)]
+	if !ok || f.Operation == "" {
+		return Conflict{}, fmt.Errorf("%s has no conflicts", path)
+	}
+	return conflict, nil
+}
+
+func (f *Fake) Continue(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fail("Continue"); err != nil {
+		return err
+	}
+	if len(f.Conflicts) > 0 {
+		return ErrConflict
+	}
+	f.Operation = ""
+	f.Behind = 0
+	return nil
+}
+
+func (f *Fake) Abort(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fail("Abort"); err != nil {
+		return err
+	}
+	f.Operation = ""
+	clear(f.Conflicts)
+	return nil
+}
+
+// Log returns the recorded commits, newest first. rev is ignored.
+func (f *Fake) Log(ctx context.Context, rev string, limit int) ([]Commit, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fail("Log"); err != nil {
+		return nil, err
+	}
+
+	var commits []Commit
+	for i := len(f.Commits) - 1; i >= 0; i-- {
+		if limit > 0 && len(commits) == limit {
+			break
+		}
+		commits = append(commits, Commit{
+			Author:  f.Commits[i].Author,
+			Date:    time.Now(),
+			Subject: f.Commits[i].Message,
+		})
+	}
+	return commits, nil
+}
+
+// Diff returns no changes, the fake doesn't keep file contents
+func (f *Fake) Diff(ctx context.Context, paths ...string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return "", f.fail("Diff")
+}
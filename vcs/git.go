@@ -0,0 +1,360 @@
+package vcs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Git is a repository worked on with the git command
+type Git struct {
+	root string
+}
+
+// Open returns the git repository containing dir, which may be any
+// directory of its working tree
+func Open(dir string) (*Git, error) {
+	g := &Git{root: dir}
+	root, err := g.run(context.Background(), "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("finding repository of %s: %w", dir, err)
+	}
+	g.root = strings.TrimSpace(root)
+	log.Debug().Str("root", g.root).Msg("Opened git repository")
+	return g, nil
+}
+
+// Root returns the top level directory of the working tree
+func (g *Git) Root() string {
+	return g.root
+}
+
+// run runs git with args in the repository root and returns its output
+func (g *Git) run(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = g.root
+	// Never wait for an editor, e.g. when continuing a rebase
+	cmd.Env = append(os.Environ(), "GIT_EDITOR=true")
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	log.Debug().Strs("args", args).Msg("Running git")
+	if err := cmd.Run(); err != nil {
+		return stdout.String(), &Error{
+			Args:   args,
+			Stdout: strings.TrimSpace(stdout.String()),
+			Stderr: strings.TrimSpace(stderr.String()),
+			Err:    err,
+		}
+	}
+	return stdout.String(), nil
+}
+
+func (g *Git) Add(ctx context.Context, paths ...string) error {
+	_, err := g.run(ctx, append([]string{"add", "-A", "--"}, paths...)...)
+	return err
+}
+
+func (g *Git) Move(ctx context.Context, from, to string) error {
+	_, err := g.run(ctx, "mv", "--", from, to)
+	return err
+}
+
+func (g *Git) Remove(ctx context.Context, path string) error {
+	_, err := g.run(ctx, "rm", "-r", "-q", "--", path)
+	return err
+}
+
+func (g *Git) Commit(ctx context.Context, message, author string) error {
+	args := []string{"commit", "-m", message}
+	if author != "" {
+		args = append(args, "--author", author)
+	}
+	_, err := g.run(ctx, args...)
+	return err
+}
+
+func (g *Git) Push(ctx context.Context) error {
+	_, err := g.run(ctx, "push")
+	return err
+}
+
+func (g *Git) Pull(ctx context.Context) error {
+	_, err := g.run(ctx, "pull", "--ff-only")
+	return err
+}
+
+func (g *Git) Fetch(ctx context.Context) error {
+	_, err := g.run(ctx, "fetch")
+	return err
+}
+
+func (g *Git) Integrate(ctx context.Context, strategy Strategy) error {
+	var args []string
+	switch strategy {
+	case Rebase:
+		args = []string{"rebase", "--autostash", "@{u}"}
+	case Merge:
+		args = []string{"merge", "--autostash", "--no-edit", "@{u}"}
+	default:
+		return fmt.Errorf("unknown strategy %q", strategy)
+	}
+	_, err := g.run(ctx, args...)
+	return g.conflicted(ctx, err)
+}
+
+func (g *Git) Conflict(ctx context.Context, path string) (Conflict, error) {
+	operation, err := g.operation(ctx)
+	if err != nil {
+		return Conflict{}, err
+	}
+
+	// Each entry is "<mode> <object> <stage>\t<path>"
+	out, err := g.run(ctx, "ls-files", "-u", "-z", "--", path)
+	if err != nil {
+		return Conflict{}, err
+	}
+	objects := make(map[string]string)
+	for _, entry := range strings.Split(out, "\x00") {
+		info, name, ok := strings.Cut(entry, "\t")
+		fields := strings.Fields(info)
+		if !ok || len(fields) != 3 {
+			continue
+		}
+		path = name
+		objects[fields[2]] = fields[1]
+	}
+	if len(objects) == 0 {
+		return Conflict{}, fmt.Errorf("%s has no conflicts", path)
+	}
+
+	// Stage 2 is the branch being merged into, which is the upstream one
+	// when rebasing, and stage 3 the one being merged
+	local, upstream := "2", "3"
+	if operation == Rebase {
+		local, upstream = upstream, local
+	}
+
+	conflict := Conflict{Path: path}
+	if object, ok := objects[local]; ok {
+		if conflict.Local, err = g.run(ctx, "cat-file", "blob", object); err != nil {
+			return Conflict{}, err
+		}
+	} else {
+		conflict.LocalDeleted = true
+	}
+	if object, ok := objects[upstream]; ok {
+		if conflict.Upstream, err = g.run(ctx, "cat-file", "blob", object); err != nil {
+			return Conflict{}, err
+		}
+	} else {
+		conflict.UpstreamDeleted = true
+	}
+	return conflict, nil
+}
+
+func (g *Git) Continue(ctx context.Context) error {
+	operation, err := g.operation(ctx)
+	if err != nil {
+		return err
+	}
+	unmerged, err := g.run(ctx, "ls-files", "-u")
+	if err != nil {
+		return err
+	}
+	if unmerged != "" {
+		return fmt.Errorf("%w: resolve every conflicted file first", ErrConflict)
+	}
+
+	switch operation {
+	case Merge:
+		_, err = g.run(ctx, "commit", "--no-edit")
+	case Rebase:
+		// Drop the commit when the resolution left it empty, because the
+		// upstream made the same change already
+		if _, diffErr := g.run(ctx, "diff", "--cached", "--quiet"); diffErr == nil {
+			_, err = g.run(ctx, "rebase", "--skip")
+		} else {
+			_, err = g.run(ctx, "rebase", "--continue")
+		}
+	default:
+		return errors.New("there is no merge or rebase to continue")
+	}
+	return g.conflicted(ctx, err)
+}
+
+func (g *Git) Abort(ctx context.Context) error {
+	operation, err := g.operation(ctx)
+	if err != nil {
+		return err
+	}
+	switch operation {
+	case Merge:
+		_, err = g.run(ctx, "merge", "--abort")
+	case Rebase:
+		_, err = g.run(ctx, "rebase", "--abort")
+	default:
+		err = errors.New("there is no merge or rebase to abort")
+	}
+	return err
+}
+
+// conflicted wraps err in ErrConflict when it left conflicted paths behind
+func (g *Git) conflicted(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if unmerged, lsErr := g.run(ctx, "ls-files", "-u"); lsErr == nil && unmerged != "" {
+		return fmt.Errorf("%w: %w", ErrConflict, err)
+	}
+	return err
+}
+
+// operation returns the merge or rebase in progress, if any
+func (g *Git) operation(ctx context.Context) (Strategy, error) {
+	out, err := g.run(ctx, "rev-parse",
+		"--git-path", "MERGE_HEAD",
+		"--git-path", "rebase-merge",
+		"--git-path", "rebase-apply")
+	if err != nil {
+		return "", err
+	}
+
+	for i, path := range strings.Split(strings.TrimSpace(out), "\n") {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(g.root, path)
+		}
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		if i == 0 {
+			return Merge, nil
+		}
+		return Rebase, nil
+	}
+	return "", nil
+}
+
+func (g *Git) Diff(ctx context.Context, paths ...string) (string, error) {
+	return g.run(ctx, append([]string{"diff", "HEAD", "--"}, paths...)...)
+}
+
+// logFormat separates the fields of a commit with NUL and commits with a
+// record separator
+const logFormat = "--format=%H%x00%an%x00%ae%x00%aI%x00%s%x1e"
+
+func (g *Git) Log(ctx context.Context, rev string, limit int) ([]Commit, error) {
+	args := []string{"log", logFormat}
+	if limit > 0 {
+		args = append(args, "-n", strconv.Itoa(limit))
+	}
+	out, err := g.run(ctx, append(args, rev, "--")...)
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []Commit
+	for _, record := range strings.Split(out, "\x1e") {
+		fields := strings.Split(strings.TrimPrefix(record, "\n"), "\x00")
+		if len(fields) != 5 {
+			continue
+		}
+		date, err := time.Parse(time.RFC3339, fields[3])
+		if err != nil {
+			return nil, fmt.Errorf("parsing date of commit %s: %w", fields[0], err)
+		}
+		commits = append(commits, Commit{
+			Hash:    fields[0],
+			Author:  fields[1],
+			Email:   fields[2],
+			Date:    date,
+			Subject: fields[4],
+		})
+	}
+	return commits, nil
+}
+
+func (g *Git) Status(ctx context.Context) (Status, error) {
+	out, err := g.run(ctx, "status", "--porcelain=v2", "--branch", "-z")
+	if err != nil {
+		return Status{}, err
+	}
+	status, err := parseStatus(out)
+	if err != nil {
+		return Status{}, err
+	}
+	if status.Operation, err = g.operation(ctx); err != nil {
+		return Status{}, err
+	}
+	return status, nil
+}
+
+// parseStatus parses the output of git status --porcelain=v2 --branch -z
+func parseStatus(out string) (Status, error) {
+	var status Status
+
+	entries := strings.Split(out, "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if entry == "" {
+			continue
+		}
+
+		switch entry[0] {
+		case '#':
+			fields := strings.Fields(entry)
+			if len(fields) < 3 {
+				continue
+			}
+			switch fields[1] {
+			case "branch.head":
+				if fields[2] != "(detached)" {
+					status.Branch = fields[2]
+				}
+			case "branch.upstream":
+				status.Upstream = fields[2]
+			case "branch.ab":
+				if len(fields) == 4 {
+					status.Ahead, _ = strconv.Atoi(strings.TrimPrefix(fields[2], "+"))
+					status.Behind, _ = strconv.Atoi(strings.TrimPrefix(fields[3], "-"))
+				}
+			}
+		case '1', '2', 'u':
+			// The path is the last of a fixed number of space separated fields
+			fieldCount := map[byte]int{'1': 9, '2': 10, 'u': 11}[entry[0]]
+			fields := strings.SplitN(entry, " ", fieldCount)
+			if len(fields) != fieldCount {
+				return Status{}, fmt.Errorf("unexpected git status entry %q", entry)
+			}
+			change := Change{
+				Path:     fields[fieldCount-1],
+				Staged:   fields[1][:1],
+				Unstaged: fields[1][1:],
+			}
+			// Renames are followed by the original path
+			if entry[0] == '2' && i+1 < len(entries) {
+				i++
+				change.OrigPath = entries[i]
+			}
+			status.Changes = append(status.Changes, change)
+		case '?':
+			status.Changes = append(status.Changes, Change{
+				Path:     strings.TrimPrefix(entry, "? "),
+				Staged:   "?",
+				Unstaged: "?",
+			})
+		}
+	}
+	return status, nil
+}
@@ -0,0 +1,637 @@
+package vcs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/ionrock/hugs/diff"
+	"github.com/rs/zerolog/log"
+)
+
+// GoGit is a repository worked on in process with go-git, for hosts without
+// the git command. Remotes are reached over the transports go-git supports:
+// local paths, file://, ssh through the ssh agent and unauthenticated
+// http(s).
+type GoGit struct {
+	mu   sync.Mutex
+	repo *git.Repository
+	wt   *git.Worktree
+	root string
+}
+
+// OpenGoGit returns the git repository containing dir, which may be any
+// directory of its working tree
+func OpenGoGit(dir string) (*GoGit, error) {
+	repo, err := git.PlainOpenWithOptions(dir, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, fmt.Errorf("finding repository of %s: %w", dir, err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("opening working tree of %s: %w", dir, err)
+	}
+
+	g := &GoGit{repo: repo, wt: wt, root: wt.Filesystem.Root()}
+	log.Debug().Str("root", g.root).Msg("Opened git repository with go-git")
+	return g, nil
+}
+
+// Root returns the top level directory of the working tree
+func (g *GoGit) Root() string {
+	return g.root
+}
+
+// rel returns path relative to the root, as go-git expects
+func (g *GoGit) rel(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		return filepath.Clean(path), nil
+	}
+	rel, err := filepath.Rel(g.root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside of the repository", path)
+	}
+	return rel, nil
+}
+
+// tracked returns the paths in the index at or under path
+func (g *GoGit) tracked(path string) ([]string, error) {
+	idx, err := g.repo.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+	prefix := filepath.ToSlash(path)
+	var paths []string
+	for _, entry := range idx.Entries {
+		if entry.Name == prefix || strings.HasPrefix(entry.Name, prefix+"/") {
+			paths = append(paths, filepath.FromSlash(entry.Name))
+		}
+	}
+	return paths, nil
+}
+
+// add stages paths relative to the root. go-git stages removed files but
+// not removed directories, so those are staged file by file.
+func (g *GoGit) add(paths ...string) error {
+	for _, path := range paths {
+		_, err := os.Lstat(filepath.Join(g.root, path))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if err == nil {
+			if _, err := g.wt.Add(path); err != nil {
+				return fmt.Errorf("adding %s: %w", path, err)
+			}
+			continue
+		}
+
+		removed, err := g.tracked(path)
+		if err != nil {
+			return err
+		}
+		if len(removed) == 0 {
+			return fmt.Errorf("pathspec %s did not match any files", path)
+		}
+		for _, file := range removed {
+			if _, err := g.wt.Add(file); err != nil {
+				return fmt.Errorf("removing %s: %w", file, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (g *GoGit) Add(ctx context.Context, paths ...string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	rels := make([]string, len(paths))
+	for i, path := range paths {
+		rel, err := g.rel(path)
+		if err != nil {
+			return err
+		}
+		rels[i] = rel
+	}
+	return g.add(rels...)
+}
+
+// Move renames a tracked file or directory. go-git only moves files, so the
+// rename is done on disk and both sides are staged.
+func (g *GoGit) Move(ctx context.Context, from, to string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	fromRel, err := g.rel(from)
+	if err != nil {
+		return err
+	}
+	toRel, err := g.rel(to)
+	if err != nil {
+		return err
+	}
+
+	tracked, err := g.tracked(fromRel)
+	if err != nil {
+		return err
+	}
+	if len(tracked) == 0 {
+		return fmt.Errorf("moving %s: not under version control", fromRel)
+	}
+	if _, err := os.Lstat(filepath.Join(g.root, toRel)); err == nil {
+		return fmt.Errorf("moving %s: %s already exists", fromRel, toRel)
+	}
+
+	if err := os.Rename(filepath.Join(g.root, fromRel), filepath.Join(g.root, toRel)); err != nil {
+		return err
+	}
+	return g.add(fromRel, toRel)
+}
+
+// Remove deletes a tracked file or directory, along with any untracked
+// files in it, and stages the removal
+func (g *GoGit) Remove(ctx context.Context, path string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	rel, err := g.rel(path)
+	if err != nil {
+		return err
+	}
+
+	tracked, err := g.tracked(rel)
+	if err != nil {
+		return err
+	}
+	if len(tracked) == 0 {
+		return fmt.Errorf("removing %s: not under version control", rel)
+	}
+
+	if err := os.RemoveAll(filepath.Join(g.root, rel)); err != nil {
+		return err
+	}
+	return g.add(rel)
+}
+
+func (g *GoGit) Commit(ctx context.Context, message, author string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	opts := &git.CommitOptions{}
+	if author != "" {
+		signature, err := parseSignature(author)
+		if err != nil {
+			return err
+		}
+		opts.Author = signature
+		// Like git, record the repository's own identity as the committer
+		opts.Committer = g.identity()
+	}
+
+	hash, err := g.wt.Commit(message, opts)
+	if err != nil {
+		return fmt.Errorf("committing: %w", err)
+	}
+	log.Debug().Str("commit", hash.String()).Msg("Committed with go-git")
+	return nil
+}
+
+// identity returns the user configured for the repository, nil when there
+// is none
+func (g *GoGit) identity() *object.Signature {
+	cfg, err := g.repo.ConfigScoped(config.SystemScope)
+	if err != nil || cfg.User.Name == "" || cfg.User.Email == "" {
+		return nil
+	}
+	return &object.Signature{Name: cfg.User.Name, Email: cfg.User.Email, When: time.Now()}
+}
+
+// parseSignature parses an author in the "Name <email>" form
+func parseSignature(author string) (*object.Signature, error) {
+	start := strings.LastIndex(author, "<")
+	end := strings.LastIndex(author, ">")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("invalid author %q", author)
+	}
+	return &object.Signature{
+		Name:  strings.TrimSpace(author[:start]),
+		Email: author[start+1 : end],
+		When:  time.Now(),
+	}, nil
+}
+
+// upstream returns the remote and the remote branch the current branch
+// tracks, and the ref that remote branch was last fetched to
+func (g *GoGit) upstream(branch string) (remote string, merge, tracking plumbing.ReferenceName, ok bool) {
+	cfg, err := g.repo.Config()
+	if err != nil {
+		return "", "", "", false
+	}
+	b, found := cfg.Branches[branch]
+	if !found || b.Remote == "" || b.Merge == "" {
+		return "", "", "", false
+	}
+	return b.Remote, b.Merge, plumbing.NewRemoteReferenceName(b.Remote, b.Merge.Short()), true
+}
+
+// branch returns the current branch, empty when HEAD is detached or points
+// at a branch without commits
+func (g *GoGit) branch() string {
+	head, err := g.repo.Head()
+	if err != nil {
+		if ref, err := g.repo.Storer.Reference(plumbing.HEAD); err == nil && ref.Type() == plumbing.SymbolicReference {
+			return ref.Target().Short()
+		}
+		return ""
+	}
+	if !head.Name().IsBranch() {
+		return ""
+	}
+	return head.Name().Short()
+}
+
+func (g *GoGit) Status(ctx context.Context) (Status, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	status := Status{Branch: g.branch()}
+
+	if remote, merge, tracking, ok := g.upstream(status.Branch); ok {
+		status.Upstream = remote + "/" + merge.Short()
+		head, headErr := g.repo.Head()
+		ref, refErr := g.repo.Reference(tracking, true)
+		if headErr == nil && refErr == nil {
+			ahead, err := g.exclusive(head.Hash(), ref.Hash(), 0)
+			if err != nil {
+				return Status{}, err
+			}
+			behind, err := g.exclusive(ref.Hash(), head.Hash(), 0)
+			if err != nil {
+				return Status{}, err
+			}
+			status.Ahead, status.Behind = len(ahead), len(behind)
+		}
+	}
+
+	files, err := g.wt.Status()
+	if err != nil {
+		return Status{}, fmt.Errorf("reading status: %w", err)
+	}
+	for path, file := range files {
+		if file.Staging == git.Unmodified && file.Worktree == git.Unmodified {
+			continue
+		}
+		status.Changes = append(status.Changes, Change{
+			Path:     filepath.ToSlash(path),
+			OrigPath: file.Extra,
+			Staged:   statusLetter(file.Staging),
+			Unstaged: statusLetter(file.Worktree),
+		})
+	}
+	slices.SortFunc(status.Changes, func(a, b Change) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return status, nil
+}
+
+// statusLetter returns git's porcelain letter for a go-git status code
+func statusLetter(code git.StatusCode) string {
+	if code == git.Unmodified {
+		return "."
+	}
+	return string(code)
+}
+
+// exclusive returns the commits reachable from hash but not from exclude,
+// newest first, stopping after limit commits when limit is positive
+func (g *GoGit) exclusive(hash, exclude plumbing.Hash, limit int) ([]*object.Commit, error) {
+	excluded := make(map[plumbing.Hash]bool)
+	if !exclude.IsZero() {
+		commit, err := g.repo.CommitObject(exclude)
+		if err != nil {
+			return nil, err
+		}
+		err = object.NewCommitPreorderIter(commit, nil, nil).ForEach(func(c *object.Commit) error {
+			excluded[c.Hash] = true
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	commit, err := g.repo.CommitObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	var commits []*object.Commit
+	err = object.NewCommitIterCTime(commit, excluded, nil).ForEach(func(c *object.Commit) error {
+		if limit > 0 && len(commits) == limit {
+			return object.ErrCanceled
+		}
+		commits = append(commits, c)
+		return nil
+	})
+	if err != nil && !errors.Is(err, object.ErrCanceled) {
+		return nil, err
+	}
+	return commits, nil
+}
+
+func (g *GoGit) Push(ctx context.Context) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	branch := g.branch()
+	remote, merge, _, ok := g.upstream(branch)
+	if !ok {
+		return fmt.Errorf("pushing: branch %q has no upstream", branch)
+	}
+
+	refSpec := config.RefSpec(plumbing.NewBranchReferenceName(branch).String() + ":" + merge.String())
+	err := g.repo.PushContext(ctx, &git.PushOptions{
+		RemoteName: remote,
+		RefSpecs:   []config.RefSpec{refSpec},
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("pushing to %s: %w", remote, err)
+	}
+	return nil
+}
+
+// Pull fast-forwards the current branch to its upstream, the only kind of
+// pull go-git supports
+func (g *GoGit) Pull(ctx context.Context) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	branch := g.branch()
+	remote, merge, _, ok := g.upstream(branch)
+	if !ok {
+		return fmt.Errorf("pulling: branch %q has no upstream", branch)
+	}
+
+	err := g.wt.PullContext(ctx, &git.PullOptions{
+		RemoteName:    remote,
+		ReferenceName: merge,
+		SingleBranch:  true,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("pulling from %s: %w", remote, err)
+	}
+	return nil
+}
+
+func (g *GoGit) Fetch(ctx context.Context) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	branch := g.branch()
+	remote, _, _, ok := g.upstream(branch)
+	if !ok {
+		return fmt.Errorf("fetching: branch %q has no upstream", branch)
+	}
+
+	err := g.repo.FetchContext(ctx, &git.FetchOptions{RemoteName: remote})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("fetching from %s: %w", remote, err)
+	}
+	return nil
+}
+
+// Integrate fast-forwards the current branch to the fetched upstream
+// commits. go-git can neither rebase nor merge, so branches that diverged
+// from their upstream need the git command.
+func (g *GoGit) Integrate(ctx context.Context, strategy Strategy) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	branch := g.branch()
+	_, _, tracking, ok := g.upstream(branch)
+	if !ok {
+		return fmt.Errorf("integrating: branch %q has no upstream", branch)
+	}
+	head, err := g.repo.Head()
+	if err != nil {
+		return err
+	}
+	ref, err := g.repo.Reference(tracking, true)
+	if err != nil {
+		return fmt.Errorf("integrating: %w", err)
+	}
+
+	ahead, err := g.exclusive(head.Hash(), ref.Hash(), 1)
+	if err != nil {
+		return err
+	}
+	if len(ahead) > 0 {
+		return fmt.Errorf("go-git can't %s: %s has diverged from %s, use the git command instead", strategy, branch, tracking.Short())
+	}
+	if head.Hash() == ref.Hash() {
+		return nil
+	}
+
+	if err := g.wt.Reset(&git.ResetOptions{Commit: ref.Hash(), Mode: git.MergeReset}); err != nil {
+		return fmt.Errorf("fast-forwarding to %s: %w", tracking.Short(), err)
+	}
+	return nil
+}
+
+// errNoIntegration is returned for the conflict handling go-git doesn't
+// need, never stopping on conflicts
+var errNoIntegration = errors.New("there is no merge or rebase in progress")
+
+func (g *GoGit) Conflict(ctx context.Context, path string) (Conflict, error) {
+	return Conflict{}, errNoIntegration
+}
+
+func (g *GoGit) Continue(ctx context.Context) error {
+	return errNoIntegration
+}
+
+func (g *GoGit) Abort(ctx context.Context) error {
+	return errNoIntegration
+}
+
+// Log supports revisions go-git can resolve, "@{u}" for the upstream of
+// the current branch and ranges of those such as "@{u}..HEAD"
+func (g *GoGit) Log(ctx context.Context, rev string, limit int) ([]Commit, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var from, to plumbing.Hash
+	var err error
+	if start, end, ok := strings.Cut(rev, ".."); ok {
+		if from, err = g.resolve(start); err != nil {
+			return nil, err
+		}
+		rev = end
+	}
+	if to, err = g.resolve(rev); err != nil {
+		return nil, err
+	}
+
+	found, err := g.exclusive(to, from, limit)
+	if err != nil {
+		return nil, err
+	}
+	commits := make([]Commit, len(found))
+	for i, c := range found {
+		commits[i] = Commit{
+			Hash:    c.Hash.String(),
+			Author:  c.Author.Name,
+			Email:   c.Author.Email,
+			Date:    c.Author.When,
+			Subject: strings.SplitN(c.Message, "\n", 2)[0],
+		}
+	}
+	return commits, nil
+}
+
+// resolve returns the commit rev refers to, HEAD when rev is empty
+func (g *GoGit) resolve(rev string) (plumbing.Hash, error) {
+	switch rev {
+	case "", "HEAD":
+		head, err := g.repo.Head()
+		if err != nil {
+			return plumbing.ZeroHash, fmt.Errorf("resolving HEAD: %w", err)
+		}
+		return head.Hash(), nil
+	case "@{u}", "@{upstream}":
+		_, _, tracking, ok := g.upstream(g.branch())
+		if !ok {
+			return plumbing.ZeroHash, fmt.Errorf("resolving %s: no upstream configured", rev)
+		}
+		ref, err := g.repo.Reference(tracking, true)
+		if err != nil {
+			return plumbing.ZeroHash, fmt.Errorf("resolving %s: %w", rev, err)
+		}
+		return ref.Hash(), nil
+	}
+
+	hash, err := g.repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("resolving %s: %w", rev, err)
+	}
+	return *hash, nil
+}
+
+// Diff compares the working tree with HEAD file by file. Each file is a
+// single hunk holding all of its lines.
+func (g *GoGit) Diff(ctx context.Context, paths ...string) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	prefixes := make([]string, len(paths))
+	for i, path := range paths {
+		rel, err := g.rel(path)
+		if err != nil {
+			return "", err
+		}
+		prefixes[i] = filepath.ToSlash(rel)
+	}
+
+	var tree *object.Tree
+	if head, err := g.repo.Head(); err == nil {
+		commit, err := g.repo.CommitObject(head.Hash())
+		if err != nil {
+			return "", err
+		}
+		if tree, err = commit.Tree(); err != nil {
+			return "", err
+		}
+	}
+
+	files, err := g.wt.Status()
+	if err != nil {
+		return "", fmt.Errorf("reading status: %w", err)
+	}
+	var changed []string
+	for path, file := range files {
+		path = filepath.ToSlash(path)
+		if file.Worktree == git.Untracked || (file.Staging == git.Unmodified && file.Worktree == git.Unmodified) {
+			continue
+		}
+		if len(prefixes) > 0 && !slices.ContainsFunc(prefixes, func(prefix string) bool {
+			return prefix == "." || path == prefix || strings.HasPrefix(path, prefix+"/")
+		}) {
+			continue
+		}
+		changed = append(changed, path)
+	}
+	slices.Sort(changed)
+
+	var b strings.Builder
+	for _, path := range changed {
+		var before, after string
+		if tree != nil {
+			if file, err := tree.File(path); err == nil {
+				if before, err = file.Contents(); err != nil {
+					return "", err
+				}
+			}
+		}
+		content, err := os.ReadFile(filepath.Join(g.root, filepath.FromSlash(path)))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		after = string(content)
+		writeUnified(&b, path, before, after)
+	}
+	return b.String(), nil
+}
+
+// writeUnified writes the changes of a file in the unified diff format
+func writeUnified(b *strings.Builder, path, before, after string) {
+	lines := diff.Lines(before, after)
+	var oldCount, newCount int
+	for _, line := range lines {
+		if line.Op != diff.Insert {
+			oldCount++
+		}
+		if line.Op != diff.Delete {
+			newCount++
+		}
+	}
+
+	fmt.Fprintf(b, "diff --git a/%s b/%s\n", path, path)
+	oldName, newName := "a/"+path, "b/"+path
+	if before == "" {
+		oldName = "/dev/null"
+	}
+	if after == "" {
+		newName = "/dev/null"
+	}
+	fmt.Fprintf(b, "--- %s\n+++ %s\n", oldName, newName)
+	fmt.Fprintf(b, "@@ -%s +%s @@\n", hunkRange(oldCount), hunkRange(newCount))
+	for _, line := range lines {
+		switch line.Op {
+		case diff.Equal:
+			b.WriteString(" ")
+		case diff.Delete:
+			b.WriteString("-")
+		case diff.Insert:
+			b.WriteString("+")
+		}
+		b.WriteString(line.Text + "\n")
+	}
+}
+
+// hunkRange formats the start and length of a hunk covering count lines
+func hunkRange(count int) string {
+	if count == 0 {
+		return "0,0"
+	}
+	return fmt.Sprintf("1,%d", count)
+}
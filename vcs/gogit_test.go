@@ -0,0 +1,275 @@
+package vcs
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gitCmd runs the git command in dir, ignoring the user's configuration,
+// and returns its trimmed output
+func gitCmd(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_CONFIG_GLOBAL="+os.DevNull, "GIT_CONFIG_NOSYSTEM=1")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newRemote returns a bare repository whose main branch holds two posts
+func newRemote(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	remote := filepath.Join(dir, "remote.git")
+	gitCmd(t, dir, "init", "-q", "--bare", remote)
+	gitCmd(t, remote, "symbolic-ref", "HEAD", "refs/heads/main")
+
+	seed := filepath.Join(dir, "seed")
+	gitCmd(t, dir, "init", "-q", seed)
+	gitCmd(t, seed, "symbolic-ref", "HEAD", "refs/heads/main")
+	gitCmd(t, seed, "config", "user.name", "Seed")
+	gitCmd(t, seed, "config", "user.email", "seed@example.com")
+	writeFile(t, seed, "content/post/first.md", "---\ntitle: First\n---\n")
+	writeFile(t, seed, "content/post/second.md", "---\ntitle: Second\n---\n")
+	gitCmd(t, seed, "add", "-A")
+	gitCmd(t, seed, "commit", "-q", "-m", "Add posts")
+	gitCmd(t, seed, "push", "-q", remote, "main")
+	return remote
+}
+
+// clone clones url into a new working tree committing as Site Owner
+func clone(t *testing.T, url string) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "site")
+	gitCmd(t, filepath.Dir(dir), "clone", "-q", url, dir)
+	gitCmd(t, dir, "config", "user.name", "Site Owner")
+	gitCmd(t, dir, "config", "user.email", "site@example.com")
+	return dir
+}
+
+// commitFile writes a file in dir and commits it with the git command
+func commitFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	writeFile(t, dir, name, content)
+	gitCmd(t, dir, "add", "-A")
+	gitCmd(t, dir, "commit", "-q", "-m", "Change "+name)
+}
+
+func TestGoGitCommit(t *testing.T) {
+	ctx := context.Background()
+	dir := clone(t, newRemote(t))
+
+	// Any directory of the working tree opens the repository
+	repo, err := OpenGoGit(filepath.Join(dir, "content", "post"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.Root() != dir {
+		t.Errorf("Root() = %q, want %q", repo.Root(), dir)
+	}
+
+	writeFile(t, dir, "content/post/new.md", "---\ntitle: New\n---\n")
+	if err := repo.Add(ctx, filepath.Join(dir, "content/post/new.md")); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Commit(ctx, "Add new post", "Jane Doe <jane@example.com>"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The user is the author, the repository's identity the committer
+	got := gitCmd(t, dir, "log", "-1", "--format=%an <%ae>|%cn <%ce>|%s")
+	if want := "Jane Doe <jane@example.com>|Site Owner <site@example.com>|Add new post"; got != want {
+		t.Errorf("commit = %q, want %q", got, want)
+	}
+	if files := gitCmd(t, dir, "show", "--name-only", "--format=", "HEAD"); files != "content/post/new.md" {
+		t.Errorf("committed files = %q, want content/post/new.md", files)
+	}
+
+	status, err := repo.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Branch != "main" || status.Upstream != "origin/main" || status.Ahead != 1 || status.Behind != 0 || !status.Clean() {
+		t.Errorf("Status() = %+v, want main one ahead of origin/main and clean", status)
+	}
+
+	commits, err := repo.Log(ctx, "@{u}..HEAD", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) != 1 {
+		t.Fatalf("Log(@{u}..HEAD) returned %d commits, want 1", len(commits))
+	}
+	if c := commits[0]; c.Hash != gitCmd(t, dir, "rev-parse", "HEAD") || c.Author != "Jane Doe" ||
+		c.Email != "jane@example.com" || c.Subject != "Add new post" {
+		t.Errorf("Log(@{u}..HEAD) = %+v, want the new commit", c)
+	}
+}
+
+func TestGoGitMoveAndRemove(t *testing.T) {
+	ctx := context.Background()
+	dir := clone(t, newRemote(t))
+	repo, err := OpenGoGit(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post := filepath.Join(dir, "content", "post")
+	if err := repo.Move(ctx, filepath.Join(post, "first.md"), filepath.Join(post, "renamed.md")); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Remove(ctx, filepath.Join(post, "second.md")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Files git doesn't track are left to the caller
+	writeFile(t, dir, "content/post/untracked.md", "")
+	if err := repo.Move(ctx, filepath.Join(post, "untracked.md"), filepath.Join(post, "moved.md")); err == nil {
+		t.Error("moving an untracked file succeeded")
+	}
+	if err := os.Remove(filepath.Join(post, "untracked.md")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Commit(ctx, "Rename and remove posts", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := gitCmd(t, dir, "log", "-1", "--format=%an <%ae>"); got != "Site Owner <site@example.com>" {
+		t.Errorf("author = %q, want the repository's identity", got)
+	}
+	if files := gitCmd(t, dir, "ls-files", "content"); files != "content/post/renamed.md" {
+		t.Errorf("tracked files = %q, want content/post/renamed.md", files)
+	}
+	if changes := gitCmd(t, dir, "status", "--porcelain"); changes != "" {
+		t.Errorf("uncommitted changes left: %q", changes)
+	}
+}
+
+func TestGoGitPush(t *testing.T) {
+	remote := newRemote(t)
+	for name, url := range map[string]string{
+		"path":     remote,
+		"file URL": "file://" + filepath.ToSlash(remote),
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			dir := clone(t, url)
+			repo, err := OpenGoGit(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			writeFile(t, dir, "content/post/"+strings.ReplaceAll(name, " ", "-")+".md", "---\ntitle: Pushed\n---\n")
+			if err := repo.Add(ctx, filepath.Join(dir, "content")); err != nil {
+				t.Fatal(err)
+			}
+			if err := repo.Commit(ctx, "Add pushed post", ""); err != nil {
+				t.Fatal(err)
+			}
+			if err := repo.Push(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			if pushed, head := gitCmd(t, remote, "rev-parse", "main"), gitCmd(t, dir, "rev-parse", "HEAD"); pushed != head {
+				t.Errorf("remote main = %s, want %s", pushed, head)
+			}
+			status, err := repo.Status(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status.Ahead != 0 || status.Behind != 0 {
+				t.Errorf("after pushing %d ahead and %d behind, want neither", status.Ahead, status.Behind)
+			}
+		})
+	}
+}
+
+func TestGoGitSync(t *testing.T) {
+	ctx := context.Background()
+	remote := newRemote(t)
+	dir := clone(t, remote)
+	other := clone(t, remote)
+	repo, err := OpenGoGit(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Commits pushed from elsewhere are fast-forwarded to
+	commitFile(t, other, "content/post/first.md", "---\ntitle: First, edited elsewhere\n---\n")
+	gitCmd(t, other, "push", "-q")
+
+	if err := repo.Fetch(ctx); err != nil {
+		t.Fatal(err)
+	}
+	status, err := repo.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Ahead != 0 || status.Behind != 1 {
+		t.Fatalf("after fetching %d ahead and %d behind, want 1 behind", status.Ahead, status.Behind)
+	}
+	if err := repo.Integrate(ctx, Rebase); err != nil {
+		t.Fatal(err)
+	}
+	if head, upstream := gitCmd(t, dir, "rev-parse", "HEAD"), gitCmd(t, other, "rev-parse", "HEAD"); head != upstream {
+		t.Errorf("HEAD = %s after integrating, want %s", head, upstream)
+	}
+	if content, err := os.ReadFile(filepath.Join(dir, "content/post/first.md")); err != nil || !strings.Contains(string(content), "edited elsewhere") {
+		t.Errorf("working tree not updated: %q, %v", content, err)
+	}
+
+	// With commits on both sides the branch has diverged, which go-git
+	// can't integrate
+	commitFile(t, other, "content/post/second.md", "---\ntitle: Second, edited elsewhere\n---\n")
+	gitCmd(t, other, "push", "-q")
+	writeFile(t, dir, "content/post/local.md", "---\ntitle: Local\n---\n")
+	if err := repo.Add(ctx, filepath.Join(dir, "content/post/local.md")); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Commit(ctx, "Add local post", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Fetch(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if status, err = repo.Status(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if status.Ahead != 1 || status.Behind != 1 {
+		t.Fatalf("%d ahead and %d behind, want 1 of each", status.Ahead, status.Behind)
+	}
+
+	head := gitCmd(t, dir, "rev-parse", "HEAD")
+	for _, strategy := range []Strategy{Rebase, Merge} {
+		err := repo.Integrate(ctx, strategy)
+		if err == nil || !strings.Contains(err.Error(), "has diverged from origin/main") {
+			t.Errorf("Integrate(%s) = %v, want an error about the diverged branch", strategy, err)
+		}
+	}
+	if after := gitCmd(t, dir, "rev-parse", "HEAD"); after != head {
+		t.Errorf("HEAD moved from %s to %s on a failed integration", head, after)
+	}
+}
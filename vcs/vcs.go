@@ -0,0 +1,207 @@
+// Package vcs records changes to the site in version control. Git is used
+// through the git command, or in process with go-git on hosts without it;
+// Fake keeps everything in memory for tests.
+package vcs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Backends a repository can be opened with
+const (
+	BackendAuto  = "auto"   // the git command when it is installed, go-git otherwise
+	BackendGit   = "git"    // the git command
+	BackendGoGit = "go-git" // go-git
+)
+
+// OpenBackend returns the git repository containing dir, worked on with
+// backend
+func OpenBackend(backend, dir string) (Repository, error) {
+	if backend == BackendAuto {
+		backend = BackendGit
+		if _, err := exec.LookPath("git"); err != nil {
+			backend = BackendGoGit
+		}
+	}
+
+	switch backend {
+	case BackendGit:
+		repo, err := Open(dir)
+		if err != nil {
+			return nil, err
+		}
+		return repo, nil
+	case BackendGoGit:
+		repo, err := OpenGoGit(dir)
+		if err != nil {
+			return nil, err
+		}
+		return repo, nil
+	default:
+		return nil, fmt.Errorf("unknown git backend %q", backend)
+	}
+}
+
+// Repository is the version control of the site. Paths are absolute or
+// relative to the repository root.
+type Repository interface {
+	// Root returns the top level directory of the working tree
+	Root() string
+
+	// Add stages the current state of paths, including their removal
+	Add(ctx context.Context, paths ...string) error
+
+	// Move renames a file or directory and stages the rename
+	Move(ctx context.Context, from, to string) error
+
+	// Remove deletes a file or directory and stages the removal
+	Remove(ctx context.Context, path string) error
+
+	// Commit records the staged changes. author is in the "Name <email>"
+	// form; when empty the repository's configured identity is used.
+	Commit(ctx context.Context, message, author string) error
+
+	// Status returns the branch and the uncommitted changes
+	Status(ctx context.Context) (Status, error)
+
+	// Push sends the commits of the current branch to its upstream
+	Push(ctx context.Context) error
+
+	// Pull brings in the commits of the upstream branch
+	Pull(ctx context.Context) error
+
+	// Fetch downloads the commits of the upstream branch without bringing
+	// them into the current branch
+	Fetch(ctx context.Context) error
+
+	// Integrate brings the fetched upstream commits into the current branch
+	// with strategy. When the changes on both sides conflict it stops with
+	// an error wrapping ErrConflict, leaving the conflicts to be resolved,
+	// staged with Add and finished with Continue, or undone with Abort.
+	Integrate(ctx context.Context, strategy Strategy) error
+
+	// Conflict returns both sides of a conflicted path
+	Conflict(ctx context.Context, path string) (Conflict, error)
+
+	// Continue finishes integrating once the conflicts are resolved. It
+	// returns an error wrapping ErrConflict if that brings up new ones.
+	Continue(ctx context.Context) error
+
+	// Abort gives up integrating, restoring the branch to how it was
+	Abort(ctx context.Context) error
+
+	// Log returns up to limit commits reachable from rev, newest first.
+	// rev may be a range such as "@{u}..HEAD".
+	Log(ctx context.Context, rev string, limit int) ([]Commit, error)
+
+	// Diff returns the uncommitted changes to paths, or to everything when
+	// no paths are given, as a unified diff
+	Diff(ctx context.Context, paths ...string) (string, error)
+}
+
+// Strategy is how upstream commits are brought into the current branch
+type Strategy string
+
+const (
+	Rebase Strategy = "rebase" // replay the local commits on top of the upstream ones
+	Merge  Strategy = "merge"  // record a merge commit joining both
+)
+
+// ErrConflict is returned when integrating stops on conflicting changes
+var ErrConflict = errors.New("conflicting changes")
+
+// Status describes the current branch and the working tree
+type Status struct {
+	Branch    string   // empty when HEAD is detached
+	Upstream  string   // empty when the branch has no upstream
+	Ahead     int      // commits not pushed to the upstream yet
+	Behind    int      // upstream commits not pulled yet
+	Operation Strategy // the integration stopped on conflicts, if any
+	Changes   []Change
+}
+
+// Clean reports whether there are no uncommitted changes
+func (s Status) Clean() bool {
+	return len(s.Changes) == 0
+}
+
+// Conflicts returns the changes with unresolved conflicts
+func (s Status) Conflicts() []Change {
+	var conflicts []Change
+	for _, change := range s.Changes {
+		if change.Conflicted() {
+			conflicts = append(conflicts, change)
+		}
+	}
+	return conflicts
+}
+
+// Change is a path with uncommitted changes. Staged and Unstaged hold git's
+// status letters, e.g. "M" for modified, "A" added, "D" deleted, "R"
+// renamed, "?" untracked and "U" unmerged; "." means unchanged.
+type Change struct {
+	Path     string
+	OrigPath string // the path before a rename
+	Staged   string
+	Unstaged string
+}
+
+// Conflicted reports whether the path has unresolved merge conflicts
+func (c Change) Conflicted() bool {
+	return c.Staged == "U" || c.Unstaged == "U" ||
+		(c.Staged == "A" && c.Unstaged == "A") || (c.Staged == "D" && c.Unstaged == "D")
+}
+
+// Conflict holds both sides of a conflicted file: the local one, from the
+// current branch, and the upstream one
+type Conflict struct {
+	Path            string
+	Local           string
+	Upstream        string
+	LocalDeleted    bool // the local side deleted the file
+	UpstreamDeleted bool // the upstream side deleted the file
+}
+
+// Commit is an entry of the history
+type Commit struct {
+	Hash    string
+	Author  string
+	Email   string
+	Date    time.Time
+	Subject string
+}
+
+// Error is a failed git command, with what it wrote
+type Error struct {
+	Args   []string
+	Stdout string
+	Stderr string
+	Err    error
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("git %s: %v", strings.Join(e.Args, " "), e.Err)
+	if output := e.Output(); output != "" {
+		msg += ": " + output
+	}
+	return msg
+}
+
+// Output returns git's explanation of the failure: what it wrote to stderr,
+// or to stdout for the failures it reports there, like having nothing to
+// commit
+func (e *Error) Output() string {
+	if e.Stderr != "" {
+		return e.Stderr
+	}
+	return e.Stdout
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
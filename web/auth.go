@@ -0,0 +1,88 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ionrock/hugs/auth"
+	"github.com/ionrock/hugs/templates"
+	"github.com/rs/zerolog/log"
+)
+
+// authenticate only lets requests by a known user through to next, with the
+// user stored in the request's context. Users that aren't logged in are
+// sent to the login page when there is one.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.Auth == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		_, local := s.Auth.(*auth.Local)
+		if local && r.URL.Path == "/login" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, ok := s.Auth.User(r)
+		if !ok {
+			if local && r.Method == http.MethodGet {
+				http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+				return
+			}
+			http.Error(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), user)))
+	})
+}
+
+func (s *Server) handleLogin(local *auth.Local) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		next := localPath(r.FormValue("next"))
+
+		var failed bool
+		if r.Method == http.MethodPost {
+			_, err := local.Login(w, r, r.FormValue("username"), r.FormValue("password"))
+			if err == nil {
+				http.Redirect(w, r, next, http.StatusSeeOther)
+				return
+			}
+			if !errors.Is(err, auth.ErrInvalidLogin) {
+				log.Error().Err(err).Msg("Error logging in")
+				http.Error(w, "Error logging in: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			failed = true
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+
+		component := templates.Login(next, failed)
+		err := component.Render(r.Context(), w)
+		if err != nil {
+			log.Error().Err(err).Msg("Error rendering login template")
+			http.Error(w, "Error rendering template: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func (s *Server) handleLogout(local *auth.Local) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		local.Logout(w, r)
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+	}
+}
+
+// localPath returns path when it points into the editor, and "/" for empty
+// paths and ones that would redirect to another site
+func localPath(path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.HasPrefix(path, `/\`) {
+		return "/"
+	}
+	return path
+}
@@ -0,0 +1,57 @@
+package web
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ionrock/hugs/diff"
+	"github.com/ionrock/hugs/posts"
+	"github.com/ionrock/hugs/templates"
+	"github.com/rs/zerolog/log"
+)
+
+// hasConflict checks the revision a post was edited at against the file on
+// disk. When the file changed in the meantime, a conflict page offering to
+// overwrite, merge or discard is rendered and true is returned.
+func (s *Server) hasConflict(w http.ResponseWriter, r *http.Request, filename, submitted string) bool {
+	revision := r.FormValue("revision")
+	if revision == "" {
+		return false
+	}
+
+	current, err := os.ReadFile(filepath.Join(s.ContentDir, filename))
+	if err != nil && !os.IsNotExist(err) {
+		log.Error().Err(err).Str("filename", filename).Msg("Error reading post")
+		http.Error(w, "Error reading post: "+err.Error(), http.StatusInternalServerError)
+		return true
+	}
+
+	currentRevision := posts.Revision(string(current))
+	if currentRevision == revision {
+		return false
+	}
+
+	log.Warn().
+		Str("filename", filename).
+		Str("revision", revision).
+		Str("current_revision", currentRevision).
+		Msg("Post changed on disk since it was loaded")
+
+	conflict := templates.ConflictData{
+		Filename: filename,
+		EditURL:  "/edit/" + strings.TrimSuffix(filename, "/index.md"),
+		Revision: currentRevision,
+		Diff:     diff.Lines(string(current), submitted),
+		Content:  submitted,
+		Merged:   diff.Merge(string(current), submitted, "on disk", "your changes"),
+	}
+
+	w.WriteHeader(http.StatusConflict)
+	component := templates.Conflict(conflict)
+	if err := component.Render(r.Context(), w); err != nil {
+		log.Error().Err(err).Str("filename", filename).Msg("Error rendering conflict template")
+	}
+	return true
+}
@@ -0,0 +1,98 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ionrock/hugs/posts"
+)
+
+// formDateLayouts are the layouts sent by date and datetime-local inputs
+var formDateLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// postEdit reads the fields of a submitted edit form. Fields that are not
+// part of the form are left unchanged; the draft checkbox is always part of
+// it and is only sent when checked.
+func postEdit(r *http.Request, post posts.Post) (posts.Edit, error) {
+	var edit posts.Edit
+
+	draft := r.FormValue("draft") != ""
+	edit.Draft = &draft
+
+	if _, ok := r.Form["body"]; ok {
+		// Browsers submit textareas with CRLF line endings
+		body := strings.ReplaceAll(r.FormValue("body"), "\r\n", "\n")
+		edit.Body = &body
+	}
+
+	if _, ok := r.Form["title"]; ok {
+		title := strings.TrimSpace(r.FormValue("title"))
+		edit.Title = &title
+	}
+
+	if _, ok := r.Form["description"]; ok {
+		description := strings.TrimSpace(r.FormValue("description"))
+		edit.Description = &description
+	}
+
+	if _, ok := r.Form["tags"]; ok {
+		tags := splitList(r.FormValue("tags"))
+		edit.Tags = &tags
+	}
+
+	if _, ok := r.Form["categories"]; ok {
+		categories := splitList(r.FormValue("categories"))
+		edit.Categories = &categories
+	}
+
+	if value := strings.TrimSpace(r.FormValue("date")); value != "" {
+		date, changed, err := formDate(value, post.Date)
+		if err != nil {
+			return posts.Edit{}, err
+		}
+		if changed {
+			edit.Date = &date
+		}
+	}
+
+	return edit, nil
+}
+
+// formDate parses a date submitted by a date input. The form has no time
+// zone, so the post's current offset is kept, and a value that only
+// differs from the current date below the input's precision is no change.
+func formDate(value string, current time.Time) (time.Time, bool, error) {
+	location := current.Location()
+	if current.IsZero() {
+		location = time.Local
+	}
+
+	for _, layout := range formDateLayouts {
+		date, err := time.ParseInLocation(layout, value, location)
+		if err != nil {
+			continue
+		}
+		if !current.IsZero() && current.Format(layout) == value {
+			return current, false, nil
+		}
+		return date, true, nil
+	}
+	return time.Time{}, false, fmt.Errorf("invalid date: %q", value)
+}
+
+// splitList splits a comma separated form value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
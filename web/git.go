@@ -0,0 +1,232 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ionrock/hugs/auth"
+	"github.com/ionrock/hugs/flash"
+	"github.com/ionrock/hugs/posts"
+	"github.com/ionrock/hugs/templates"
+	"github.com/ionrock/hugs/vcs"
+	"github.com/rs/zerolog/log"
+)
+
+// errNoRepository is returned for git operations on a site outside of a
+// git repository
+var errNoRepository = errors.New("the site is not in a git repository")
+
+// Git commands run to completion once started, even when the request that
+// started them is cancelled, so they never leave a half written index or
+// commit behind.
+
+// commitChanges commits the given files, relative to the content directory,
+// to the git repository. Removed files are staged as deletions. Without
+// files only what is already staged is committed. The user of ctx, if any,
+// is recorded as the commit's author.
+func (s *Server) commitChanges(ctx context.Context, message string, files ...string) error {
+	log.Debug().Strs("files", files).Str("message", message).Msg("Committing changes to git")
+
+	if s.VCS == nil {
+		return errNoRepository
+	}
+	ctx = context.WithoutCancel(ctx)
+
+	s.gitMu.Lock()
+	defer s.gitMu.Unlock()
+
+	// Commits would end up in the middle of the stopped rebase or merge
+	if stopped, err := s.syncStopped(ctx); err != nil {
+		return err
+	} else if stopped {
+		return errSyncStopped
+	}
+
+	// Stage the files
+	if len(files) > 0 {
+		paths := make([]string, len(files))
+		for i, file := range files {
+			paths[i] = filepath.Join(s.ContentDir, file)
+		}
+		if err := s.VCS.Add(ctx, paths...); err != nil {
+			return err
+		}
+	}
+
+	// Commit the changes
+	var author string
+	if user, ok := auth.FromContext(ctx); ok {
+		author = user.Author()
+	}
+	if err := s.VCS.Commit(ctx, message, author); err != nil {
+		return err
+	}
+
+	log.Info().Strs("files", files).Str("message", message).Msg("Changes committed to git")
+	return nil
+}
+
+// commit commits the files like commitChanges and tells the user whether
+// it worked with a flash message. message describes the change.
+func (s *Server) commit(w http.ResponseWriter, r *http.Request, message string, files ...string) {
+	err := s.commitChanges(r.Context(), message, files...)
+	switch {
+	case err == nil, errors.Is(err, errNoRepository):
+		flash.Set(w, r, flash.Success, message)
+	default:
+		log.Warn().Err(err).Msg("Failed to commit changes to git")
+		flash.Set(w, r, flash.Error, message+", but committing it failed: "+gitMessage(err))
+	}
+}
+
+// gitMessage returns what git had to say about err, or err itself when it
+// didn't come from git
+func gitMessage(err error) string {
+	var gitErr *vcs.Error
+	if errors.As(err, &gitErr) && gitErr.Output() != "" {
+		return gitErr.Output()
+	}
+	return err.Error()
+}
+
+// gitMove returns a posts.MoveFunc moving files with git, so the move is
+// staged as a rename. Files git doesn't track are moved directly.
+func (s *Server) gitMove(ctx context.Context) posts.MoveFunc {
+	return func(from, to string) error {
+		if s.VCS == nil {
+			return os.Rename(from, to)
+		}
+
+		s.gitMu.Lock()
+		defer s.gitMu.Unlock()
+
+		if err := s.VCS.Move(context.WithoutCancel(ctx), from, to); err != nil {
+			log.Debug().Err(err).Str("from", from).Msg("git mv failed, moving directly")
+			return os.Rename(from, to)
+		}
+		return nil
+	}
+}
+
+// gitRemove returns a posts.RemoveFunc removing files with git, staging the
+// deletion, and setting staged once it did. Files git can't remove, because
+// it doesn't track them or they have local changes, are removed directly.
+func (s *Server) gitRemove(ctx context.Context, staged *bool) posts.RemoveFunc {
+	return func(path string) error {
+		if s.VCS == nil {
+			return os.RemoveAll(path)
+		}
+
+		s.gitMu.Lock()
+		defer s.gitMu.Unlock()
+
+		if err := s.VCS.Remove(context.WithoutCancel(ctx), path); err != nil {
+			log.Debug().Err(err).Str("path", path).Msg("git rm failed, removing directly")
+			return os.RemoveAll(path)
+		}
+		*staged = true
+		return nil
+	}
+}
+
+// hasChanges reports whether git sees uncommitted changes to path, relative
+// to the content directory, or to anything under it
+func (s *Server) hasChanges(ctx context.Context, path string) (bool, error) {
+	if s.VCS == nil {
+		return false, errNoRepository
+	}
+
+	s.gitMu.Lock()
+	defer s.gitMu.Unlock()
+
+	status, err := s.VCS.Status(context.WithoutCancel(ctx))
+	if err != nil {
+		return false, err
+	}
+
+	// Status paths are relative to the repository root
+	root, err := filepath.EvalSymlinks(s.VCS.Root())
+	if err != nil {
+		return false, err
+	}
+	dir, err := filepath.EvalSymlinks(s.ContentDir)
+	if err != nil {
+		return false, err
+	}
+	rel, err := filepath.Rel(root, filepath.Join(dir, path))
+	if err != nil {
+		return false, err
+	}
+	rel = filepath.ToSlash(rel)
+
+	for _, change := range status.Changes {
+		if change.Path == rel || strings.HasPrefix(change.Path, rel+"/") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// push sends the committed changes to the remote repository
+func (s *Server) push(ctx context.Context) error {
+	if s.VCS == nil {
+		return errNoRepository
+	}
+
+	s.gitMu.Lock()
+	defer s.gitMu.Unlock()
+	return s.VCS.Push(context.WithoutCancel(ctx))
+}
+
+// hasUnpushedChanges checks if there are commits that haven't been pushed to
+// the remote. Branches without an upstream are assumed to have some.
+func (s *Server) hasUnpushedChanges(ctx context.Context) bool {
+	if s.VCS == nil {
+		return false
+	}
+
+	s.gitMu.Lock()
+	defer s.gitMu.Unlock()
+
+	status, err := s.VCS.Status(ctx)
+	if err != nil {
+		log.Debug().Err(err).Msg("Error checking for unpushed changes, assuming changes exist")
+		return true
+	}
+	return status.Upstream == "" || status.Ahead > 0
+}
+
+// unpushedLimit is how many unpushed commits the status page lists
+const unpushedLimit = 20
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if s.VCS == nil {
+		http.Error(w, "The site isn't in a git repository", http.StatusNotFound)
+		return
+	}
+
+	s.gitMu.Lock()
+	status, err := s.VCS.Status(r.Context())
+	var unpushed []vcs.Commit
+	if err == nil && status.Upstream != "" && status.Ahead > 0 {
+		unpushed, err = s.VCS.Log(r.Context(), "@{u}..HEAD", unpushedLimit)
+	}
+	s.gitMu.Unlock()
+	if err != nil {
+		log.Error().Err(err).Msg("Error reading git status")
+		http.Error(w, "Error reading git status: "+gitMessage(err), http.StatusInternalServerError)
+		return
+	}
+
+	component := templates.Status(status, unpushed)
+	err = component.Render(r.Context(), w)
+	if err != nil {
+		log.Error().Err(err).Msg("Error rendering status template")
+		http.Error(w, "Error rendering template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,129 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// pathError is returned for a user supplied path that is rejected
+type pathError struct {
+	Path   string
+	Reason string
+}
+
+func (e *pathError) Error() string {
+	return fmt.Sprintf("invalid path %q: %s", e.Path, e.Reason)
+}
+
+// resolvePath validates a user supplied, slash separated path relative to
+// the content directory and returns it cleaned. Paths that are absolute,
+// escape the content directory (directly or through a symlink) or touch
+// hidden files such as .git are rejected.
+func (s *Server) resolvePath(name string) (string, error) {
+	if name == "" {
+		return "", &pathError{name, "path is empty"}
+	}
+	if strings.ContainsRune(name, 0) || strings.Contains(name, `\`) {
+		return "", &pathError{name, "path contains invalid characters"}
+	}
+	if strings.HasPrefix(name, "/") || filepath.IsAbs(name) {
+		return "", &pathError{name, "path must be relative to the content directory"}
+	}
+
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if clean == "." || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", &pathError{name, "path is outside the content directory"}
+	}
+	for _, part := range strings.Split(clean, string(filepath.Separator)) {
+		if strings.HasPrefix(part, ".") {
+			return "", &pathError{name, "hidden files can't be edited"}
+		}
+	}
+
+	// Follow symlinks in the part of the path that exists already
+	root, err := filepath.EvalSymlinks(s.ContentDir)
+	if err != nil {
+		return "", fmt.Errorf("resolving content directory: %w", err)
+	}
+	existing := filepath.Join(s.ContentDir, clean)
+	for {
+		if _, err := os.Lstat(existing); err == nil || existing == s.ContentDir {
+			break
+		}
+		existing = filepath.Dir(existing)
+	}
+	real, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return "", &pathError{name, "path can't be resolved"}
+	}
+	if real != root && !strings.HasPrefix(real, root+string(filepath.Separator)) {
+		return "", &pathError{name, "path is outside the content directory"}
+	}
+
+	return filepath.ToSlash(clean), nil
+}
+
+// resolvePost validates the path of an existing post: a markdown file or a
+// page bundle directory
+func (s *Server) resolvePost(name string) (string, error) {
+	clean, err := s.resolvePath(name)
+	if err != nil {
+		return "", err
+	}
+
+	if strings.HasSuffix(clean, ".md") {
+		return clean, nil
+	}
+	index := filepath.Join(s.ContentDir, clean, "index.md")
+	if info, err := os.Stat(index); err == nil && !info.IsDir() {
+		return clean, nil
+	}
+	return "", &pathError{name, "not a markdown file or page bundle"}
+}
+
+// resolveMarkdown validates the path of a markdown file about to be written
+func (s *Server) resolveMarkdown(name string) (string, error) {
+	clean, err := s.resolvePath(name)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasSuffix(clean, ".md") {
+		return "", &pathError{name, "only markdown files can be written"}
+	}
+	return clean, nil
+}
+
+// resolveResource validates the name of a resource within the page bundle
+// at bundle, returning its path relative to the content directory
+func (s *Server) resolveResource(bundle, name string) (string, error) {
+	if name == "" || strings.HasPrefix(name, "/") {
+		return "", &pathError{name, "resource name must be relative to the bundle"}
+	}
+
+	clean, err := s.resolvePath(bundle + "/" + name)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(clean, bundle+"/") {
+		return "", &pathError{name, "resource is outside the bundle"}
+	}
+	if clean == bundle+"/index.md" {
+		return "", &pathError{name, "index.md is the bundle's content, not a resource"}
+	}
+	return clean, nil
+}
+
+// badPath responds with a 400 for rejected paths and reports whether err was
+// one. Other errors are left to the caller.
+func badPath(w http.ResponseWriter, err error) bool {
+	var pathErr *pathError
+	if !errors.As(err, &pathErr) {
+		return false
+	}
+	http.Error(w, pathErr.Error(), http.StatusBadRequest)
+	return true
+}
@@ -0,0 +1,179 @@
+package web
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// pathServer returns a server on a content directory holding a post, a page
+// bundle, a .git directory and a symlink pointing outside of it
+func pathServer(t *testing.T) *Server {
+	t.Helper()
+	root := t.TempDir()
+	content := filepath.Join(root, "content")
+	outside := filepath.Join(root, "outside")
+
+	for _, dir := range []string{
+		filepath.Join(content, "post", "bundle"),
+		filepath.Join(content, ".git"),
+		outside,
+	} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{
+		filepath.Join(content, "post", "first.md"),
+		filepath.Join(content, "post", "bundle", "index.md"),
+		filepath.Join(content, "post", "bundle", "photo.jpg"),
+		filepath.Join(outside, "secret.md"),
+	} {
+		if err := os.WriteFile(file, []byte("---\ntitle: Test\n---\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(content, "post", "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	return &Server{ContentDir: content}
+}
+
+func TestResolvePath(t *testing.T) {
+	s := pathServer(t)
+
+	tests := []struct {
+		name string
+		want string // empty when the path is rejected
+	}{
+		{"post/first.md", "post/first.md"},
+		{"post/./new.md", "post/new.md"},
+		{"post/../post/first.md", "post/first.md"},
+		{"post/new/dir/new.md", "post/new/dir/new.md"},
+		{"", ""},
+		{"../x.md", ""},
+		{"..", ""},
+		{".", ""},
+		{"post/../../x.md", ""},
+		{"/etc/passwd", ""},
+		{filepath.Join(s.ContentDir, "post", "first.md"), ""},
+		{`post\first.md`, ""},
+		{`..\x.md`, ""},
+		{"post/first.md\x00.jpg", ""},
+		{".git/config", ""},
+		{"post/.hidden.md", ""},
+		{"post/.drafts/x.md", ""},
+		{"post/link/secret.md", ""},
+		{"post/link/new.md", ""},
+	}
+	for _, test := range tests {
+		got, err := s.resolvePath(test.name)
+		if test.want == "" {
+			var pathErr *pathError
+			if !errors.As(err, &pathErr) {
+				t.Errorf("resolvePath(%q) = %q, %v; want a path error", test.name, got, err)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("resolvePath(%q) = %q, %v; want %q", test.name, got, err, test.want)
+		}
+	}
+}
+
+func TestResolvePost(t *testing.T) {
+	s := pathServer(t)
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"post/first.md", "post/first.md"},
+		{"post/bundle", "post/bundle"},
+		{"post/bundle/", "post/bundle"},
+		{"post", ""},
+		{"post/bundle/photo.jpg", ""},
+		{"post/missing", ""},
+		{"../x.md", ""},
+		{"post/link/secret.md", ""},
+	}
+	for _, test := range tests {
+		got, err := s.resolvePost(test.name)
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("resolvePost(%q) = %q; want an error", test.name, got)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("resolvePost(%q) = %q, %v; want %q", test.name, got, err, test.want)
+		}
+	}
+}
+
+func TestResolveMarkdown(t *testing.T) {
+	s := pathServer(t)
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"post/first.md", "post/first.md"},
+		{"post/new.md", "post/new.md"},
+		{"post/bundle/index.md", "post/bundle/index.md"},
+		{"post/new.html", ""},
+		{"post/first.md.sh", ""},
+		{"post/bundle", ""},
+		{"post/new", ""},
+		{"../x.md", ""},
+		{".git/hooks/x.md", ""},
+	}
+	for _, test := range tests {
+		got, err := s.resolveMarkdown(test.name)
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("resolveMarkdown(%q) = %q; want an error", test.name, got)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("resolveMarkdown(%q) = %q, %v; want %q", test.name, got, err, test.want)
+		}
+	}
+}
+
+func TestResolveResource(t *testing.T) {
+	s := pathServer(t)
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"photo.jpg", "post/bundle/photo.jpg"},
+		{"images/new.png", "post/bundle/images/new.png"},
+		{"", ""},
+		{"index.md", ""},
+		{"./index.md", ""},
+		{"../", ""},
+		{"..", ""},
+		{"../first.md", ""},
+		{"../../x.md", ""},
+		{"/photo.jpg", ""},
+		{".hidden", ""},
+		{`images\new.png`, ""},
+	}
+	for _, test := range tests {
+		got, err := s.resolveResource("post/bundle", test.name)
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("resolveResource(%q) = %q; want an error", test.name, got)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("resolveResource(%q) = %q, %v; want %q", test.name, got, err, test.want)
+		}
+	}
+}
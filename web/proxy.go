@@ -0,0 +1,75 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ionrock/hugs/posts"
+	"github.com/ionrock/hugs/templates"
+	"github.com/rs/zerolog/log"
+)
+
+// SitePrefix is the path the Hugo server's site is proxied under. Hugo has
+// to be started with a base URL ending in it.
+const SitePrefix = "/site/"
+
+// hugoProxy returns a handler proxying requests to the Hugo server. Paths
+// are passed on as they are, Hugo serves the site under SitePrefix itself.
+// While Hugo isn't ready requests are answered with a 503.
+func (s *Server) hugoProxy() (http.Handler, error) {
+	target, err := url.Parse(s.Hugo.URL())
+	if err != nil {
+		return nil, fmt.Errorf("parsing Hugo server URL: %w", err)
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		log.Warn().Err(err).Str("path", r.URL.Path).Msg("Hugo server unavailable")
+		http.Error(w, "Hugo server unavailable, see /hugo: "+err.Error(), http.StatusBadGateway)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.Hugo.Ready() {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Hugo server is "+s.Hugo.Status().State.String()+", see /hugo", http.StatusServiceUnavailable)
+			return
+		}
+
+		// Live reload keeps its websocket open, beyond the server's timeouts
+		if r.Header.Get("Upgrade") != "" {
+			rc := http.NewResponseController(w)
+			rc.SetReadDeadline(time.Time{})
+			rc.SetWriteDeadline(time.Time{})
+		}
+		proxy.ServeHTTP(w, r)
+	}), nil
+}
+
+// siteURL returns the address of the post on the proxied Hugo site, or ""
+// when Hugo isn't running. Posts are assumed to use Hugo's default
+// permalinks, see posts.Post.URL.
+func (s *Server) siteURL(post posts.Post) string {
+	if s.Hugo == nil {
+		return ""
+	}
+	return strings.TrimSuffix(SitePrefix, "/") + post.URL()
+}
+
+func (s *Server) handleHugo(w http.ResponseWriter, r *http.Request) {
+	if s.Hugo == nil {
+		http.Error(w, "The Hugo server isn't enabled, start hugs with --hugo-server", http.StatusNotFound)
+		return
+	}
+
+	component := templates.Hugo(s.Hugo.Status(), SitePrefix)
+	err := component.Render(r.Context(), w)
+	if err != nil {
+		log.Error().Err(err).Msg("Error rendering hugo template")
+		http.Error(w, "Error rendering template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
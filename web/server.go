@@ -1,92 +1,136 @@
 package web
 
 import (
-	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/ionrock/hugs/auth"
+	"github.com/ionrock/hugs/csrf"
+	"github.com/ionrock/hugs/flash"
+	"github.com/ionrock/hugs/hugo"
 	"github.com/ionrock/hugs/posts"
+	"github.com/ionrock/hugs/preview"
+	"github.com/ionrock/hugs/slug"
 	"github.com/ionrock/hugs/templates"
+	"github.com/ionrock/hugs/vcs"
 	"github.com/rs/zerolog/log"
 )
 
 // Server represents the web server for the Hugo blog editor
 type Server struct {
-	ContentDir string
-	Port       string
-}
+	SiteDir    string // root of the Hugo site
+	ContentDir string // the site's content directory
+	Addr       string // address to listen on, e.g. ":8080" or "127.0.0.1:8080"
 
-// commitChanges commits the saved post to the git repository
-func (s *Server) commitChanges(filename, title string) error {
-	log.Debug().Str("filename", filename).Str("title", title).Msg("Committing changes to git")
+	// ArchiveSection is the section archived posts are moved to. When empty
+	// posts are archived in place, as drafts flagged archived.
+	ArchiveSection string
 
-	// Get the repository root directory (parent of content directory)
-	repoDir := filepath.Dir(filepath.Dir(s.ContentDir))
-	
-	// Stage the file
-	gitAdd := exec.Command("git", "add", filepath.Join("content", "post", filename))
-	gitAdd.Dir = repoDir
-	if err := gitAdd.Run(); err != nil {
-		return fmt.Errorf("git add failed: %w", err)
-	}
+	// Hugo is the supervised Hugo server proxied under SitePrefix, nil when
+	// Hugo isn't run by the editor
+	Hugo *hugo.Supervisor
 
-	// Create commit message
-	commitMsg := fmt.Sprintf("Updated post '%s'", title)
-	
-	// Commit the changes
-	gitCommit := exec.Command("git", "commit", "-m", commitMsg)
-	gitCommit.Dir = repoDir
-	if err := gitCommit.Run(); err != nil {
-		return fmt.Errorf("git commit failed: %w", err)
-	}
+	// Auth identifies the users of the editor, nil lets anyone in
+	Auth auth.Authenticator
 
-	log.Info().Str("filename", filename).Str("title", title).Msg("Changes committed to git")
-	return nil
+	// VCS records the changes made in the editor, nil when the site isn't
+	// in a git repository
+	VCS vcs.Repository
+
+	// SyncStrategy is how commits pushed from elsewhere are brought in
+	// before pushing, vcs.Rebase when empty
+	SyncStrategy vcs.Strategy
+
+	// SyncInterval is how often to sync in the background, never when zero
+	SyncInterval time.Duration
+
+	// TLSCert and TLSKey are the certificate and key files HTTPS is served
+	// with. Plain HTTP is served when they are empty.
+	TLSCert string
+	TLSKey  string
+
+	// RedirectAddr is an address plain HTTP requests are accepted on and
+	// redirected to HTTPS, e.g. ":80". Only used with TLS.
+	RedirectAddr string
+
+	// HSTS makes browsers use HTTPS only for the editor from then on. It
+	// should stay off with self-signed certificates, as browsers then no
+	// longer let users accept the certificate.
+	HSTS bool
+
+	mu             sync.Mutex
+	httpServer     *http.Server
+	redirectServer *http.Server
+
+	// gitMu serializes git commands, so they don't trip over each other's
+	// index lock and Shutdown can wait for the one running
+	gitMu sync.Mutex
 }
 
-// New creates a new server instance
-func New(contentDir, port string) (*Server, error) {
-	// Get absolute path for the content directory
-	if contentDir != "" {
-		absPath, err := filepath.Abs(contentDir)
+// Timeouts of the HTTP server. Writes allow for slow git pushes and large
+// resource uploads.
+const (
+	readHeaderTimeout = 10 * time.Second
+	readTimeout       = time.Minute
+	writeTimeout      = 2 * time.Minute
+	idleTimeout       = 2 * time.Minute
+)
+
+// New creates a new server instance for the Hugo site in siteDir, listening
+// on addr: a port, or a host and port
+func New(siteDir, addr string) (*Server, error) {
+	// Get absolute path for the site directory
+	if siteDir != "" {
+		absPath, err := filepath.Abs(siteDir)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get absolute path: %w", err)
 		}
-		contentDir = filepath.Join(absPath, "content", "post")
+		siteDir = absPath
 	} else {
 		// Get the current working directory
 		wd, err := os.Getwd()
 		if err != nil {
 			return nil, fmt.Errorf("failed to get working directory: %w", err)
 		}
-
-		// Use default Hugo blog directory
-		contentDir = filepath.Join(wd, "content", "post")
-
+		siteDir = wd
 	}
 
 	// Ensure the content directory exists
+	contentDir := filepath.Join(siteDir, "content")
 	if _, err := os.Stat(contentDir); os.IsNotExist(err) {
 		log.Error().Str("dir", contentDir).Msg("Content directory not found")
 		return nil, err
 	}
 
-	// Format port
-	if !strings.HasPrefix(port, ":") {
-		port = ":" + port
+	// A bare port listens on all interfaces
+	if !strings.Contains(addr, ":") {
+		addr = ":" + addr
+	}
+
+	// Changes are committed to the repository the site is in
+	repo, err := vcs.OpenBackend(vcs.BackendAuto, siteDir)
+	if err != nil {
+		log.Warn().Err(err).Msg("Site is not in a git repository, changes won't be committed")
 	}
 
 	return &Server{
+		SiteDir:    siteDir,
 		ContentDir: contentDir,
-		Port:       port,
+		Addr:       addr,
+		VCS:        repo,
 	}, nil
 }
 
-// Start starts the web server
+// Start starts the web server and blocks until it fails or Shutdown is
+// called, in which case nil is returned
 func (s *Server) Start() error {
 	// Set up routes with method-specific patterns
 	mux := http.NewServeMux()
@@ -95,37 +139,144 @@ func (s *Server) Start() error {
 	mux.HandleFunc("GET /", s.handleIndex)
 	mux.HandleFunc("GET /edit/", s.handleEdit)
 	mux.HandleFunc("GET /new", s.handleNew)
-	mux.HandleFunc("POST /new", s.handleNew)
-	mux.HandleFunc("POST /save", s.handleSave)
-	mux.HandleFunc("GET /push", s.handlePush)
+	mux.HandleFunc("POST /new", s.holdEdits(s.handleNew))
+	mux.HandleFunc("POST /save", s.holdEdits(s.handleSave))
+	mux.HandleFunc("POST /rename", s.holdEdits(s.handleRename))
+	mux.HandleFunc("GET /delete/", s.handleDeleteConfirm)
+	mux.HandleFunc("POST /delete", s.holdEdits(s.handleDelete))
+	mux.HandleFunc("POST /archive", s.holdEdits(s.handleArchive))
+	mux.HandleFunc("GET /preview/", s.handlePreview)
+	mux.HandleFunc("POST /preview", s.handlePreviewBody)
+	mux.HandleFunc("GET /raw/", s.handleRaw)
+	mux.HandleFunc("POST /raw", s.holdEdits(s.handleRawSave))
+	mux.HandleFunc("POST /push", s.handlePush)
+	mux.HandleFunc("GET /status", s.handleStatus)
+	mux.HandleFunc("POST /sync", s.handleSync)
+	mux.HandleFunc("GET /sync", s.handleSyncConflicts)
+	mux.HandleFunc("GET /sync/conflict", s.handleSyncConflict)
+	mux.HandleFunc("POST /sync/resolve", s.handleSyncResolve)
+	mux.HandleFunc("POST /sync/continue", s.handleSyncContinue)
+	mux.HandleFunc("POST /sync/abort", s.handleSyncAbort)
+	mux.HandleFunc("GET /resource", s.handleResource)
+	mux.HandleFunc("POST /resources/upload", s.holdEdits(s.handleUploadResource))
+	mux.HandleFunc("POST /resources/delete", s.holdEdits(s.handleDeleteResource))
+
+	// Serve the site rendered by Hugo, including its live reload endpoints
+	mux.HandleFunc("GET /hugo", s.handleHugo)
+	if s.Hugo != nil {
+		proxy, err := s.hugoProxy()
+		if err != nil {
+			return err
+		}
+		mux.Handle("GET "+SitePrefix, proxy)
+		mux.Handle("GET /livereload.js", proxy)
+		mux.Handle("GET /livereload", proxy)
+		log.Info().Str("hugo", s.Hugo.URL()).Str("path", SitePrefix).Msg("Proxying Hugo server")
+	}
+
+	// Require a user for everything but logging in, and a CSRF token for
+	// everything that changes something
+	if local, ok := s.Auth.(*auth.Local); ok {
+		mux.HandleFunc("GET /login", s.handleLogin(local))
+		mux.HandleFunc("POST /login", s.handleLogin(local))
+		mux.HandleFunc("POST /logout", s.handleLogout(local))
+	}
+	if s.Auth == nil {
+		log.Warn().Msg("Authentication is disabled, anyone who can reach the editor can edit and push")
+	}
+
+	handler := csrf.Protect(s.authenticate(s.flashes(mux)), s.forbidden)
+	if s.tlsEnabled() && s.HSTS {
+		handler = strictTransport(handler)
+	}
+
+	s.mu.Lock()
+	s.httpServer = &http.Server{
+		Addr:              s.Addr,
+		Handler:           handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+	}
+	httpServer := s.httpServer
+	s.mu.Unlock()
 
 	log.Info().Str("content_dir", s.ContentDir).Msg("Using content directory")
-	log.Info().Str("address", "http://localhost"+s.Port).Msg("Starting server")
-	return http.ListenAndServe(s.Port, mux)
+
+	// Keep up with the upstream branch while serving
+	if s.SyncInterval > 0 && s.VCS != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go s.syncPeriodically(ctx)
+	}
+
+	var err error
+	if s.tlsEnabled() {
+		if s.RedirectAddr != "" {
+			redirectServer, err := s.startRedirect()
+			if err != nil {
+				return fmt.Errorf("starting HTTP redirect: %w", err)
+			}
+			s.mu.Lock()
+			s.redirectServer = redirectServer
+			s.mu.Unlock()
+		}
+
+		httpServer.TLSConfig = tlsConfig()
+		log.Info().Str("address", s.Addr).Str("cert", s.TLSCert).Msg("Starting HTTPS server")
+		err = httpServer.ListenAndServeTLS(s.TLSCert, s.TLSKey)
+	} else {
+		log.Info().Str("address", s.Addr).Msg("Starting server")
+		err = httpServer.ListenAndServe()
+	}
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Shutdown stops the server gracefully: it stops accepting connections,
+// waits for in-flight requests until ctx is done and then for the running
+// git command, if any, so a commit is never cut off halfway
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	httpServer := s.httpServer
+	redirectServer := s.redirectServer
+	s.mu.Unlock()
+
+	var err error
+	if redirectServer != nil {
+		redirectServer.Close()
+	}
+	if httpServer != nil {
+		err = httpServer.Shutdown(ctx)
+	}
+
+	s.gitMu.Lock()
+	s.gitMu.Unlock()
+
+	log.Info().Msg("Server stopped")
+	return err
 }
 
-// hasUnpushedChanges checks if there are commits that haven't been pushed to the remote
-func (s *Server) hasUnpushedChanges() bool {
-	// Get the repository root directory (parent of content directory)
-	repoDir := filepath.Dir(filepath.Dir(s.ContentDir))
-	
-	// Check if there are unpushed commits
-	// git log @{u}..HEAD will list commits that are in HEAD but not in the upstream branch
-	cmd := exec.Command("git", "log", "@{u}..HEAD", "--oneline")
-	cmd.Dir = repoDir
-	
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	
-	// If there's an error, it could be because there's no upstream branch
-	// In that case, we'll assume there are changes to push
-	if err := cmd.Run(); err != nil {
-		log.Debug().Err(err).Msg("Error checking for unpushed changes, assuming changes exist")
-		return true
-	}
-	
-	// If the output is not empty, there are unpushed commits
-	return out.String() != ""
+// allPosts returns the posts of every section. Files that can't be parsed
+// are left out.
+func (s *Server) allPosts() ([]posts.Post, error) {
+	sections, err := posts.ListSections(s.ContentDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var allPosts []posts.Post
+	for _, section := range sections {
+		sectionPosts, _, err := posts.ListPosts(s.ContentDir, section.Path)
+		if err != nil {
+			return nil, err
+		}
+		allPosts = append(allPosts, sectionPosts...)
+	}
+	return allPosts, nil
 }
 
 func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
@@ -134,20 +285,45 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get all posts
-	postList, err := posts.ListPosts(s.ContentDir)
+	sections, err := posts.ListSections(s.ContentDir)
 	if err != nil {
-		log.Error().Err(err).Msg("Error reading posts")
-		http.Error(w, "Error reading posts: "+err.Error(), http.StatusInternalServerError)
+		log.Error().Err(err).Msg("Error reading sections")
+		http.Error(w, "Error reading sections: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	
+
+	// Show a single section when one is selected, all of them otherwise.
+	// Archived posts are only listed on their own.
+	selected := r.URL.Query().Get("section")
+	archived := r.URL.Query().Get("archived") != "" || (selected != "" && selected == s.ArchiveSection)
+	var postList []posts.Post
+	var problems []posts.ParseError
+	for _, section := range sections {
+		if selected != "" && section.Path != selected {
+			continue
+		}
+
+		sectionPosts, sectionProblems, err := posts.ListPosts(s.ContentDir, section.Path)
+		if err != nil {
+			log.Error().Err(err).Msg("Error reading posts")
+			http.Error(w, "Error reading posts: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, post := range sectionPosts {
+			if s.isArchived(post) == archived {
+				postList = append(postList, post)
+			}
+		}
+		problems = append(problems, sectionProblems...)
+	}
+	posts.SortByDate(postList)
+
 	// Check if there are unpushed changes
-	hasChanges := s.hasUnpushedChanges()
+	hasChanges := s.hasUnpushedChanges(r.Context())
 	log.Debug().Bool("has_unpushed_changes", hasChanges).Msg("Checked for unpushed changes")
 
 	// Render the template
-	component := templates.Index(postList, hasChanges)
+	component := templates.Index(postList, problems, sections, selected, archived, hasChanges, s.VCS != nil, s.Hugo != nil)
 	err = component.Render(r.Context(), w)
 	if err != nil {
 		log.Error().Err(err).Msg("Error rendering index template")
@@ -156,6 +332,36 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// forbidden responds to requests refused by the CSRF check
+func (s *Server) forbidden(w http.ResponseWriter, r *http.Request, reason string) {
+	w.WriteHeader(http.StatusForbidden)
+	component := templates.Forbidden(reason)
+	err := component.Render(r.Context(), w)
+	if err != nil {
+		log.Error().Err(err).Msg("Error rendering forbidden template")
+	}
+}
+
+// flashes shows flash messages on the editor's pages, leaving them alone
+// for the site served under SitePrefix, which live reload may refresh at
+// any time
+func (s *Server) flashes(next http.Handler) http.Handler {
+	load := flash.Load(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, SitePrefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		load.ServeHTTP(w, r)
+	})
+}
+
+// isArchived reports whether post was archived, in place or by moving it to
+// the archive section
+func (s *Server) isArchived(post posts.Post) bool {
+	return post.Archived() || (s.ArchiveSection != "" && post.Section == s.ArchiveSection)
+}
+
 func (s *Server) handleEdit(w http.ResponseWriter, r *http.Request) {
 
 	// Get the filename from the URL
@@ -165,16 +371,37 @@ func (s *Server) handleEdit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	filename, err := s.resolvePost(filename)
+	if badPath(w, err) {
+		return
+	}
+
 	// Read the post
-	post, err := posts.ReadPost(filepath.Join(s.ContentDir, filename))
+	post, err := posts.GetPost(s.ContentDir, filename)
 	if err != nil {
 		log.Error().Err(err).Str("filename", filename).Msg("Error reading post")
 		http.Error(w, "Error reading post: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	// Suggest the tags and categories already in use
+	allPosts, err := s.allPosts()
+	if err != nil {
+		log.Error().Err(err).Msg("Error reading posts")
+		http.Error(w, "Error reading posts: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	tags, categories := posts.Terms(allPosts)
+
+	sections, err := posts.ListSections(s.ContentDir)
+	if err != nil {
+		log.Error().Err(err).Msg("Error reading sections")
+		http.Error(w, "Error reading sections: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	// Render the template
-	component := templates.Edit(post)
+	component := templates.Edit(post, tags, categories, sections, s.siteURL(post))
 	err = component.Render(r.Context(), w)
 	if err != nil {
 		log.Error().Err(err).Str("filename", filename).Msg("Error rendering edit template")
@@ -184,6 +411,13 @@ func (s *Server) handleEdit(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleNew(w http.ResponseWriter, r *http.Request) {
+	sections, err := posts.ListSections(s.ContentDir)
+	if err != nil {
+		log.Error().Err(err).Msg("Error reading sections")
+		http.Error(w, "Error reading sections: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	if r.Method == http.MethodPost {
 		title := r.FormValue("title")
 		if title == "" {
@@ -191,23 +425,35 @@ func (s *Server) handleNew(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		post, err := posts.CreateNewPost(s.ContentDir, title)
+		section := r.FormValue("section")
+		if !slices.ContainsFunc(sections, func(s posts.Section) bool { return s.Path == section }) {
+			http.Error(w, "Unknown section: "+section, http.StatusBadRequest)
+			return
+		}
+
+		post, err := posts.CreateNewPost(s.ContentDir, section, title)
 		if err != nil {
 			log.Error().Err(err).Str("title", title).Msg("Error creating new post")
 			http.Error(w, "Error creating post: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		log.Info().Str("filename", post.Filename).Msg("Created new post")
+		log.Info().Str("path", post.Path).Msg("Created new post")
 
 		// Redirect to edit the new post
-		http.Redirect(w, r, "/edit/"+post.Filename, http.StatusSeeOther)
+		http.Redirect(w, r, "/edit/"+post.Path, http.StatusSeeOther)
 		return
 	}
 
+	// Preselect the section the index was showing, falling back to "post"
+	selected := r.URL.Query().Get("section")
+	if selected == "" {
+		selected = "post"
+	}
+
 	// Render the new post form template
-	component := templates.New()
-	err := component.Render(r.Context(), w)
+	component := templates.New(sections, selected)
+	err = component.Render(r.Context(), w)
 	if err != nil {
 		log.Error().Err(err).Msg("Error rendering new post template")
 		http.Error(w, "Error rendering template: "+err.Error(), http.StatusInternalServerError)
@@ -218,73 +464,488 @@ func (s *Server) handleNew(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handlePush(w http.ResponseWriter, r *http.Request) {
 	log.Info().Msg("Pushing changes to remote repository")
 
-	// Get the repository root directory (parent of content directory)
-	repoDir := filepath.Dir(filepath.Dir(s.ContentDir))
-	
-	// Execute git push
-	gitPush := exec.Command("git", "push")
-	gitPush.Dir = repoDir
-	
-	if err := gitPush.Run(); err != nil {
+	// Go back to the page the push was started from
+	next := localPath(r.FormValue("next"))
+
+	// Bring in what was pushed from elsewhere first, or the push is rejected
+	if _, err := s.sync(r.Context()); errors.Is(err, vcs.ErrConflict) {
+		log.Warn().Err(err).Msg("Changes conflict with the remote repository")
+		flash.Set(w, r, flash.Error, "Your changes conflict with the ones on the remote, resolve the conflicts before pushing")
+		http.Redirect(w, r, "/sync", http.StatusSeeOther)
+		return
+	} else if err != nil {
+		log.Error().Err(err).Msg("Failed to sync before pushing")
+		flash.Set(w, r, flash.Error, "Syncing before pushing failed: "+gitMessage(err))
+		http.Redirect(w, r, next, http.StatusSeeOther)
+		return
+	}
+
+	if err := s.push(r.Context()); err != nil {
 		log.Error().Err(err).Msg("Failed to push changes to remote repository")
-		http.Error(w, "Error pushing changes: "+err.Error(), http.StatusInternalServerError)
+		flash.Set(w, r, flash.Error, "Pushing failed: "+gitMessage(err))
+		http.Redirect(w, r, next, http.StatusSeeOther)
 		return
 	}
-	
+
 	log.Info().Msg("Successfully pushed changes to remote repository")
-	
-	// Redirect back to the post list
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	flash.Set(w, r, flash.Success, "Pushed changes to the remote repository")
+
+	http.Redirect(w, r, next, http.StatusSeeOther)
 }
 
 func (s *Server) handleSave(w http.ResponseWriter, r *http.Request) {
 
 	// Get form values
 	filename := r.FormValue("filename")
-	content := r.FormValue("content")
 
 	if filename == "" {
 		http.Error(w, "Filename is required", http.StatusBadRequest)
 		return
 	}
 
+	filename, err := s.resolveMarkdown(filename)
+	if badPath(w, err) {
+		return
+	}
+
+	// Start from the post on disk, so front matter keys the form doesn't
+	// cover are kept as they are
+	post, err := posts.GetPost(s.ContentDir, filename)
+	if err != nil {
+		log.Error().Err(err).Str("filename", filename).Msg("Error reading post")
+		http.Error(w, "Error reading post, fix it in the raw editor at /raw/"+filename+": "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Merge the fields of the form into the post
+	edit, err := postEdit(r, post)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	post.Apply(edit)
+
+	if post.Title == "" {
+		http.Error(w, "Title is required", http.StatusBadRequest)
+		return
+	}
+
+	rendered, err := post.Render()
+	if err != nil {
+		log.Error().Err(err).Str("filename", filename).Msg("Error rendering post")
+		http.Error(w, "Error saving post: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Refuse to overwrite changes made since the post was loaded
+	if s.hasConflict(w, r, filename, rendered) {
+		return
+	}
+
 	log.Debug().
 		Str("filename", filename).
 		Str("dir", s.ContentDir).
 		Msg("Saving post")
 
-	path := filepath.Join(s.ContentDir, filename)
-	file, err := os.Create(path)
-	if err != nil {
-		log.Error().Err(err).Str("path", path).Msg("Failed to create post file")
+	if err := posts.SavePost(s.ContentDir, post); err != nil {
+		log.Error().Err(err).Str("filename", filename).Msg("Error saving post")
 		http.Error(w, "Error saving post: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer file.Close()
 
-	// Write the front matter
-	_, err = fmt.Fprintf(file, content)
+	log.Info().Str("filename", filename).Msg("Post saved")
+
+	// Commit the changes to git
+	s.commit(w, r, fmt.Sprintf("Updated post '%s'", post.Title), filename)
+
+	// Redirect back to the post list
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (s *Server) handleRename(w http.ResponseWriter, r *http.Request) {
+	path, err := s.resolvePost(r.FormValue("post"))
+	if badPath(w, err) {
+		return
+	}
 
+	post, err := posts.GetPost(s.ContentDir, path)
 	if err != nil {
-		log.Error().Err(err).Str("filename", filename).Msg("Error saving post")
-		http.Error(w, "Error saving post: "+err.Error(), http.StatusInternalServerError)
+		log.Error().Err(err).Str("path", path).Msg("Error reading post")
+		http.Error(w, "Error reading post: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Info().Str("filename", filename).Msg("Post saved")
+	sections, err := posts.ListSections(s.ContentDir)
+	if err != nil {
+		log.Error().Err(err).Msg("Error reading sections")
+		http.Error(w, "Error reading sections: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	section := r.FormValue("section")
+	if !slices.ContainsFunc(sections, func(s posts.Section) bool { return s.Path == section }) {
+		http.Error(w, "Unknown section: "+section, http.StatusBadRequest)
+		return
+	}
+
+	// Names typed by the user are made slugs, other names are left to the
+	// posts already using them
+	name := slug.Make(r.FormValue("name"))
+	if name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+
+	renamed, err := posts.RenamePost(s.ContentDir, post, posts.Rename{
+		Section: section,
+		Name:    name,
+		Alias:   r.FormValue("alias") != "",
+		Move:    s.gitMove(r.Context()),
+	})
+	if errors.Is(err, slug.ErrExists) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("path", path).Msg("Error renaming post")
+		http.Error(w, "Error renaming post: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if renamed.Path == post.Path {
+		http.Redirect(w, r, "/edit/"+post.Path, http.StatusSeeOther)
+		return
+	}
+
+	log.Info().Str("from", post.Path).Str("to", renamed.Path).Msg("Post renamed")
+
+	// The old path was staged by git mv, committing the new one records the
+	// rename together with the front matter changes
+	message := fmt.Sprintf("Renamed post '%s' to %s", post.Title, renamed.Path)
+	s.commit(w, r, message, renamed.Path)
+
+	http.Redirect(w, r, "/edit/"+renamed.Path, http.StatusSeeOther)
+}
+
+func (s *Server) handleDeleteConfirm(w http.ResponseWriter, r *http.Request) {
+	path, err := s.resolvePost(strings.TrimPrefix(r.URL.Path, "/delete/"))
+	if badPath(w, err) {
+		return
+	}
+
+	post, err := posts.GetPost(s.ContentDir, path)
+	if err != nil {
+		log.Error().Err(err).Str("path", path).Msg("Error reading post")
+		http.Error(w, "Error reading post: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	component := templates.Delete(post, s.ArchiveSection, s.isArchived(post))
+	err = component.Render(r.Context(), w)
+	if err != nil {
+		log.Error().Err(err).Str("path", path).Msg("Error rendering delete template")
+		http.Error(w, "Error rendering template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
+	path, err := s.resolvePost(r.FormValue("post"))
+	if badPath(w, err) {
+		return
+	}
+
+	// Read the post for its title, deleting works for broken files too
+	title := path
+	if post, err := posts.GetPost(s.ContentDir, path); err == nil {
+		title = post.Title
+	}
+
+	var staged bool
+	if err := posts.DeletePost(s.ContentDir, posts.Post{Path: path}, s.gitRemove(r.Context(), &staged)); err != nil {
+		log.Error().Err(err).Str("path", path).Msg("Error deleting post")
+		http.Error(w, "Error deleting post: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Info().Str("path", path).Msg("Post deleted")
+
+	// git rm staged the deletion already. Posts removed directly have their
+	// deletion staged by path, unless git never tracked them, e.g. created
+	// and never saved, which leaves nothing to commit.
+	message := fmt.Sprintf("Deleted post '%s'", title)
+	if staged {
+		s.commit(w, r, message)
+	} else if changed, err := s.hasChanges(r.Context(), path); err == nil && !changed {
+		flash.Set(w, r, flash.Success, message)
+	} else {
+		s.commit(w, r, message, path)
+	}
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (s *Server) handleArchive(w http.ResponseWriter, r *http.Request) {
+	path, err := s.resolvePost(r.FormValue("post"))
+	if badPath(w, err) {
+		return
+	}
+
+	post, err := posts.GetPost(s.ContentDir, path)
+	if err != nil {
+		log.Error().Err(err).Str("path", path).Msg("Error reading post")
+		http.Error(w, "Error reading post: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if s.isArchived(post) {
+		http.Error(w, "Post is archived already", http.StatusBadRequest)
+		return
+	}
+
+	archived, err := posts.ArchivePost(s.ContentDir, post, s.ArchiveSection, s.gitMove(r.Context()))
+	if errors.Is(err, slug.ErrExists) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("path", path).Msg("Error archiving post")
+		http.Error(w, "Error archiving post: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Info().Str("from", post.Path).Str("to", archived.Path).Msg("Post archived")
+
+	s.commit(w, r, fmt.Sprintf("Archived post '%s'", post.Title), archived.Path)
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (s *Server) handlePreview(w http.ResponseWriter, r *http.Request) {
+	path, err := s.resolvePost(strings.TrimPrefix(r.URL.Path, "/preview/"))
+	if badPath(w, err) {
+		return
+	}
+
+	post, err := posts.GetPost(s.ContentDir, path)
+	if err != nil {
+		log.Error().Err(err).Str("path", path).Msg("Error reading post")
+		http.Error(w, "Error reading post: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	html, err := preview.Render(post.Body)
+	if err != nil {
+		log.Error().Err(err).Str("path", path).Msg("Error rendering preview")
+		http.Error(w, "Error rendering preview: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	component := templates.Preview(post, html)
+	err = component.Render(r.Context(), w)
+	if err != nil {
+		log.Error().Err(err).Str("path", path).Msg("Error rendering preview template")
+		http.Error(w, "Error rendering template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// handlePreviewBody renders the submitted markdown for the live preview of
+// the editor, responding with just the HTML of the body
+func (s *Server) handlePreviewBody(w http.ResponseWriter, r *http.Request) {
+	html, err := preview.Render(r.FormValue("body"))
+	if err != nil {
+		log.Error().Err(err).Msg("Error rendering preview")
+		http.Error(w, "Error rendering preview: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(html))
+}
+
+func (s *Server) handleRaw(w http.ResponseWriter, r *http.Request) {
+
+	// Get the filename from the URL
+	filename := strings.TrimPrefix(r.URL.Path, "/raw/")
+	if filename == "" {
+		http.Error(w, "Filename is required", http.StatusBadRequest)
+		return
+	}
+
+	filename, err := s.resolveMarkdown(filename)
+	if badPath(w, err) {
+		return
+	}
+
+	// Read the file as is, it may not parse as a post
+	content, err := os.ReadFile(filepath.Join(s.ContentDir, filename))
+	if err != nil {
+		log.Error().Err(err).Str("filename", filename).Msg("Error reading file")
+		http.Error(w, "Error reading file: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Show the parse problem, if there still is one
+	var problem string
+	if _, err := posts.GetPost(s.ContentDir, filename); err != nil {
+		problem = err.Error()
+	}
 
-	// Extract post title from content for commit message
-	title, err := posts.NewPostFromMarkdown(content)
+	// Render the template
+	component := templates.Raw(filename, string(content), posts.Revision(string(content)), problem)
+	err = component.Render(r.Context(), w)
 	if err != nil {
-		log.Warn().Err(err).Msg("Could not extract title for commit message")
-		title = filename // Fallback to using filename if title extraction fails
+		log.Error().Err(err).Str("filename", filename).Msg("Error rendering raw template")
+		http.Error(w, "Error rendering template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (s *Server) handleRawSave(w http.ResponseWriter, r *http.Request) {
+
+	// Get form values, browsers submit textareas with CRLF line endings
+	filename := r.FormValue("filename")
+	content := strings.ReplaceAll(r.FormValue("content"), "\r\n", "\n")
+
+	if filename == "" {
+		http.Error(w, "Filename is required", http.StatusBadRequest)
+		return
+	}
+
+	filename, err := s.resolveMarkdown(filename)
+	if badPath(w, err) {
+		return
 	}
 
+	// Refuse to overwrite changes made since the file was loaded
+	if s.hasConflict(w, r, filename, content) {
+		return
+	}
+
+	log.Debug().
+		Str("filename", filename).
+		Str("dir", s.ContentDir).
+		Msg("Saving raw file")
+
+	path := filepath.Join(s.ContentDir, filename)
+	if err := posts.WriteFileAtomic(path, []byte(content)); err != nil {
+		log.Error().Err(err).Str("path", path).Msg("Failed to write file")
+		http.Error(w, "Error saving file: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Info().Str("filename", filename).Msg("Raw file saved")
+
 	// Commit the changes to git
-	if err := s.commitChanges(filename, title); err != nil {
-		log.Warn().Err(err).Msg("Failed to commit changes to git")
+	s.commit(w, r, fmt.Sprintf("Fixed '%s'", filename), filename)
+
+	// Keep editing while the file still doesn't parse
+	if _, err := posts.GetPost(s.ContentDir, filename); err != nil {
+		http.Redirect(w, r, "/raw/"+filename, http.StatusSeeOther)
+		return
 	}
 
 	// Redirect back to the post list
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
+
+// readBundle reads the page bundle named by the "post" form value
+func (s *Server) readBundle(r *http.Request) (posts.Post, error) {
+	path, err := s.resolvePost(r.FormValue("post"))
+	if err != nil {
+		return posts.Post{}, err
+	}
+
+	post, err := posts.GetPost(s.ContentDir, path)
+	if err != nil {
+		return posts.Post{}, err
+	}
+	if !post.IsBundle {
+		return posts.Post{}, fmt.Errorf("%s is not a page bundle", post.Path)
+	}
+	return post, nil
+}
+
+func (s *Server) handleResource(w http.ResponseWriter, r *http.Request) {
+	post, err := s.readBundle(r)
+	if badPath(w, err) {
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Error reading bundle")
+		http.Error(w, "Error reading bundle: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	name, err := s.resolveResource(post.Path, r.FormValue("name"))
+	if badPath(w, err) {
+		return
+	}
+
+	http.ServeFile(w, r, filepath.Join(s.ContentDir, name))
+}
+
+func (s *Server) handleUploadResource(w http.ResponseWriter, r *http.Request) {
+	post, err := s.readBundle(r)
+	if badPath(w, err) {
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Error reading bundle")
+		http.Error(w, "Error reading bundle: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "File is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	name := filepath.Base(header.Filename)
+	if _, err := s.resolveResource(post.Path, name); badPath(w, err) {
+		return
+	}
+
+	if err := posts.SaveResource(filepath.Join(s.ContentDir, post.Path), name, file); err != nil {
+		log.Error().Err(err).Str("post", post.Path).Str("name", name).Msg("Error saving resource")
+		http.Error(w, "Error saving resource: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Info().Str("post", post.Path).Str("name", name).Msg("Resource uploaded")
+
+	message := fmt.Sprintf("Added '%s' to post '%s'", name, post.Title)
+	s.commit(w, r, message, filepath.Join(post.Path, name))
+
+	http.Redirect(w, r, "/edit/"+post.Path, http.StatusSeeOther)
+}
+
+func (s *Server) handleDeleteResource(w http.ResponseWriter, r *http.Request) {
+	post, err := s.readBundle(r)
+	if badPath(w, err) {
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Error reading bundle")
+		http.Error(w, "Error reading bundle: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	name := r.FormValue("name")
+	if _, err := s.resolveResource(post.Path, name); badPath(w, err) {
+		return
+	}
+
+	if err := posts.DeleteResource(filepath.Join(s.ContentDir, post.Path), name); err != nil {
+		log.Error().Err(err).Str("post", post.Path).Str("name", name).Msg("Error deleting resource")
+		http.Error(w, "Error deleting resource: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Info().Str("post", post.Path).Str("name", name).Msg("Resource deleted")
+
+	message := fmt.Sprintf("Removed '%s' from post '%s'", name, post.Title)
+	s.commit(w, r, message, filepath.Join(post.Path, name))
+
+	http.Redirect(w, r, "/edit/"+post.Path, http.StatusSeeOther)
+}
@@ -0,0 +1,286 @@
+package web
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ionrock/hugs/flash"
+	"github.com/ionrock/hugs/vcs"
+)
+
+// fakeServer returns a server on a site holding post/first.md, recording
+// its commits in a fake repository
+func fakeServer(t *testing.T) (*Server, *vcs.Fake) {
+	t.Helper()
+	site := t.TempDir()
+	content := filepath.Join(site, "content")
+	if err := os.MkdirAll(filepath.Join(content, "post"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	post := "---\ntitle: First\ndraft: false\n---\nHello\n"
+	if err := os.WriteFile(filepath.Join(content, "post", "first.md"), []byte(post), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := vcs.NewFake(site)
+	return &Server{SiteDir: site, ContentDir: content, VCS: repo}, repo
+}
+
+// post sends a form to handler and returns the response
+func post(handler http.HandlerFunc, path string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+// flashMessage returns the flash message set by a response
+func flashMessage(t *testing.T, rec *httptest.ResponseRecorder) flash.Message {
+	t.Helper()
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name != "hugs_flash" {
+			continue
+		}
+		value, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var message flash.Message
+		if err := json.Unmarshal(value, &message); err != nil {
+			t.Fatal(err)
+		}
+		return message
+	}
+	t.Fatal("no flash message set")
+	return flash.Message{}
+}
+
+func TestSaveCommits(t *testing.T) {
+	s, repo := fakeServer(t)
+
+	rec := post(s.handleSave, "/save", url.Values{
+		"filename": {"post/first.md"},
+		"title":    {"Renamed title"},
+		"body":     {"Hello again\n"},
+	})
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusSeeOther, rec.Body)
+	}
+
+	if len(repo.Commits) != 1 {
+		t.Fatalf("got %d commits, want 1", len(repo.Commits))
+	}
+	commit := repo.Commits[0]
+	if want := "Updated post 'Renamed title'"; commit.Message != want {
+		t.Errorf("commit message = %q, want %q", commit.Message, want)
+	}
+	if want := []string{"content/post/first.md"}; !slices.Equal(commit.Paths, want) {
+		t.Errorf("committed paths = %q, want %q", commit.Paths, want)
+	}
+
+	message := flashMessage(t, rec)
+	if message.Kind != flash.Success || message.Text != commit.Message {
+		t.Errorf("flash = %+v, want a success with the commit message", message)
+	}
+}
+
+func TestSaveCommitFailure(t *testing.T) {
+	s, repo := fakeServer(t)
+	repo.Errors["Commit"] = &vcs.Error{
+		Args:   []string{"commit"},
+		Stderr: "fatal: unable to auto-detect email address",
+		Err:    errors.New("exit status 128"),
+	}
+
+	rec := post(s.handleSave, "/save", url.Values{
+		"filename": {"post/first.md"},
+		"title":    {"First"},
+		"body":     {"Changed\n"},
+	})
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusSeeOther, rec.Body)
+	}
+
+	// The post is saved even though it couldn't be committed
+	saved, err := os.ReadFile(filepath.Join(s.ContentDir, "post", "first.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(saved), "Changed\n") {
+		t.Errorf("post not saved: %q", saved)
+	}
+
+	message := flashMessage(t, rec)
+	want := "Updated post 'First', but committing it failed: fatal: unable to auto-detect email address"
+	if message.Kind != flash.Error || message.Text != want {
+		t.Errorf("flash = %+v, want an error %q", message, want)
+	}
+}
+
+func TestPush(t *testing.T) {
+	s, repo := fakeServer(t)
+	repo.Ahead = 1
+
+	rec := post(s.handlePush, "/push", url.Values{"next": {"/status"}})
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/status" {
+		t.Fatalf("got %d to %q, want a redirect to /status", rec.Code, rec.Header().Get("Location"))
+	}
+	if repo.Fetches != 1 || repo.Pushes != 1 {
+		t.Errorf("got %d fetches and %d pushes, want 1 of each", repo.Fetches, repo.Pushes)
+	}
+	if message := flashMessage(t, rec); message.Kind != flash.Success {
+		t.Errorf("flash = %+v, want a success", message)
+	}
+}
+
+func TestPushFailure(t *testing.T) {
+	s, repo := fakeServer(t)
+	repo.Errors["Push"] = &vcs.Error{
+		Args:   []string{"push"},
+		Stderr: "fatal: could not read from remote repository",
+		Err:    errors.New("exit status 128"),
+	}
+
+	rec := post(s.handlePush, "/push", url.Values{"next": {"//evil.example"}})
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/" {
+		t.Fatalf("got %d to %q, want a redirect to /", rec.Code, rec.Header().Get("Location"))
+	}
+	if repo.Pushes != 0 {
+		t.Errorf("got %d pushes, want none", repo.Pushes)
+	}
+
+	message := flashMessage(t, rec)
+	want := "Pushing failed: fatal: could not read from remote repository"
+	if message.Kind != flash.Error || message.Text != want {
+		t.Errorf("flash = %+v, want an error %q", message, want)
+	}
+}
+
+func TestPushStopsOnConflicts(t *testing.T) {
+	s, repo := fakeServer(t)
+	repo.Behind = 1
+	repo.Conflicts["content/post/first.md"] = vcs.Conflict{Path: "content/post/first.md"}
+
+	rec := post(s.handlePush, "/push", url.Values{"next": {"/"}})
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/sync" {
+		t.Fatalf("got %d to %q, want a redirect to /sync", rec.Code, rec.Header().Get("Location"))
+	}
+	if repo.Pushes != 0 {
+		t.Errorf("got %d pushes, want none", repo.Pushes)
+	}
+
+	// Edits are refused until the conflicts are resolved
+	rec = post(s.holdEdits(s.handleSave), "/save", url.Values{
+		"filename": {"post/first.md"},
+		"title":    {"First"},
+	})
+	if rec.Code != http.StatusConflict {
+		t.Errorf("save during a stopped sync: status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+}
+
+func TestDeleteCommits(t *testing.T) {
+	s, repo := fakeServer(t)
+
+	rec := post(s.handleDelete, "/delete", url.Values{"post": {"post/first.md"}})
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusSeeOther, rec.Body)
+	}
+
+	if _, err := os.Stat(filepath.Join(s.ContentDir, "post", "first.md")); !os.IsNotExist(err) {
+		t.Errorf("post not deleted: %v", err)
+	}
+	if len(repo.Commits) != 1 {
+		t.Fatalf("got %d commits, want 1", len(repo.Commits))
+	}
+	if want := []string{"content/post/first.md"}; !slices.Equal(repo.Commits[0].Paths, want) {
+		t.Errorf("committed paths = %q, want %q", repo.Commits[0].Paths, want)
+	}
+}
+
+// gitCmd runs the git command in dir and returns its trimmed output
+func gitCmd(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_CONFIG_GLOBAL="+os.DevNull, "GIT_CONFIG_NOSYSTEM=1")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestDeleteCommitsWithGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	for _, backend := range []string{vcs.BackendGit, vcs.BackendGoGit} {
+		t.Run(backend, func(t *testing.T) {
+			site := t.TempDir()
+			content := filepath.Join(site, "content")
+			gitCmd(t, site, "init", "-q")
+			gitCmd(t, site, "config", "user.name", "Site Owner")
+			gitCmd(t, site, "config", "user.email", "site@example.com")
+			for _, name := range []string{"clean", "changed", "untracked"} {
+				post := "---\ntitle: " + name + "\n---\n"
+				if err := os.MkdirAll(filepath.Join(content, "post"), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(content, "post", name+".md"), []byte(post), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			gitCmd(t, site, "add", "content/post/clean.md", "content/post/changed.md")
+			gitCmd(t, site, "commit", "-q", "-m", "Add posts")
+
+			// git rm refuses to remove files with local changes
+			changed := filepath.Join(content, "post", "changed.md")
+			if err := os.WriteFile(changed, []byte("---\ntitle: changed\n---\nEdited\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			repo, err := vcs.OpenBackend(backend, site)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := &Server{SiteDir: site, ContentDir: content, VCS: repo}
+
+			for _, name := range []string{"clean", "changed", "untracked"} {
+				rec := post(s.handleDelete, "/delete", url.Values{"post": {"post/" + name + ".md"}})
+				if rec.Code != http.StatusSeeOther {
+					t.Fatalf("deleting %s: status = %d: %s", name, rec.Code, rec.Body)
+				}
+				if message := flashMessage(t, rec); message.Kind != flash.Success {
+					t.Errorf("deleting %s: flash = %+v, want a success", name, message)
+				}
+			}
+
+			// Each tracked post is deleted in its own commit, nothing is left
+			// staged for the next one
+			if got := gitCmd(t, site, "log", "--format=%s"); got != "Deleted post 'changed'\nDeleted post 'clean'\nAdd posts" {
+				t.Errorf("history = %q", got)
+			}
+			for rev, want := range map[string]string{"HEAD": "content/post/changed.md", "HEAD~": "content/post/clean.md"} {
+				if got := gitCmd(t, site, "show", "--name-only", "--format=", rev); got != want {
+					t.Errorf("%s changed %q, want %q", rev, got, want)
+				}
+			}
+			if got := gitCmd(t, site, "status", "--porcelain"); got != "" {
+				t.Errorf("uncommitted changes left: %q", got)
+			}
+		})
+	}
+}
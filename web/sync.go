@@ -0,0 +1,347 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ionrock/hugs/diff"
+	"github.com/ionrock/hugs/flash"
+	"github.com/ionrock/hugs/posts"
+	"github.com/ionrock/hugs/templates"
+	"github.com/ionrock/hugs/vcs"
+	"github.com/rs/zerolog/log"
+)
+
+// errSyncStopped is returned while a sync waits for its conflicts to be
+// resolved
+var errSyncStopped = errors.New("a sync is stopped on conflicting changes, resolve them on the sync page first")
+
+// syncStrategy returns how upstream commits are brought in
+func (s *Server) syncStrategy() vcs.Strategy {
+	if s.SyncStrategy == "" {
+		return vcs.Rebase
+	}
+	return s.SyncStrategy
+}
+
+// sync brings in the commits pushed to the upstream branch from elsewhere,
+// fetching them and integrating them with the sync strategy. It returns how
+// many commits were brought in, and an error wrapping vcs.ErrConflict when
+// they conflict with the local ones.
+func (s *Server) sync(ctx context.Context) (int, error) {
+	if s.VCS == nil {
+		return 0, errNoRepository
+	}
+	ctx = context.WithoutCancel(ctx)
+
+	s.gitMu.Lock()
+	defer s.gitMu.Unlock()
+
+	status, err := s.VCS.Status(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if status.Operation != "" {
+		return 0, fmt.Errorf("%w: %w", vcs.ErrConflict, errSyncStopped)
+	}
+	if status.Upstream == "" {
+		log.Debug().Str("branch", status.Branch).Msg("No upstream branch to sync with")
+		return 0, nil
+	}
+
+	if err := s.VCS.Fetch(ctx); err != nil {
+		return 0, err
+	}
+	if status, err = s.VCS.Status(ctx); err != nil {
+		return 0, err
+	}
+	if status.Behind == 0 {
+		return 0, nil
+	}
+
+	log.Info().
+		Int("commits", status.Behind).
+		Str("upstream", status.Upstream).
+		Str("strategy", string(s.syncStrategy())).
+		Msg("Bringing in upstream changes")
+	return status.Behind, s.VCS.Integrate(ctx, s.syncStrategy())
+}
+
+// syncPeriodically syncs every SyncInterval until ctx is done
+func (s *Server) syncPeriodically(ctx context.Context) {
+	log.Info().Dur("interval", s.SyncInterval).Msg("Syncing with the upstream branch periodically")
+
+	ticker := time.NewTicker(s.SyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		count, err := s.sync(ctx)
+		switch {
+		case errors.Is(err, vcs.ErrConflict):
+			log.Warn().Err(err).Msg("Sync stopped on conflicts, resolve them at /sync")
+		case err != nil:
+			log.Error().Err(err).Msg("Failed to sync")
+		case count > 0:
+			log.Info().Int("commits", count).Msg("Synced with the upstream branch")
+		}
+	}
+}
+
+// syncStopped reports whether a sync is waiting for its conflicts to be
+// resolved. Callers hold gitMu.
+func (s *Server) syncStopped(ctx context.Context) (bool, error) {
+	status, err := s.VCS.Status(ctx)
+	if err != nil {
+		return false, err
+	}
+	return status.Operation != "", nil
+}
+
+// holdEdits refuses changes to the site while a sync is stopped on
+// conflicts, as they would get mixed into its resolution. The browser keeps
+// what was submitted, to be sent again once the sync is done.
+func (s *Server) holdEdits(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.VCS != nil {
+			s.gitMu.Lock()
+			stopped, err := s.syncStopped(r.Context())
+			s.gitMu.Unlock()
+			if err != nil {
+				log.Debug().Err(err).Msg("Error checking for a stopped sync")
+			}
+			if stopped {
+				http.Error(w, "Your changes weren't saved, a sync is stopped on conflicting changes. Resolve them at /sync, then go back and send your changes again.", http.StatusConflict)
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+func (s *Server) handleSync(w http.ResponseWriter, r *http.Request) {
+	next := localPath(r.FormValue("next"))
+
+	count, err := s.sync(r.Context())
+	switch {
+	case errors.Is(err, vcs.ErrConflict):
+		log.Warn().Err(err).Msg("Sync stopped on conflicts")
+		flash.Set(w, r, flash.Error, "Changes on the remote conflict with yours, choose how to resolve them")
+		next = "/sync"
+	case err != nil:
+		log.Error().Err(err).Msg("Failed to sync")
+		flash.Set(w, r, flash.Error, "Syncing failed: "+gitMessage(err))
+	case count == 0:
+		flash.Set(w, r, flash.Success, "Already up to date")
+	default:
+		noun := "commits"
+		if count == 1 {
+			noun = "commit"
+		}
+		flash.Set(w, r, flash.Success, fmt.Sprintf("Brought in %d %s from the remote", count, noun))
+	}
+
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+// handleSyncConflicts lists the conflicts a sync stopped on
+func (s *Server) handleSyncConflicts(w http.ResponseWriter, r *http.Request) {
+	if s.VCS == nil {
+		http.Error(w, "The site isn't in a git repository", http.StatusNotFound)
+		return
+	}
+
+	s.gitMu.Lock()
+	status, err := s.VCS.Status(r.Context())
+	s.gitMu.Unlock()
+	if err != nil {
+		log.Error().Err(err).Msg("Error reading git status")
+		http.Error(w, "Error reading git status: "+gitMessage(err), http.StatusInternalServerError)
+		return
+	}
+
+	component := templates.SyncConflicts(status)
+	err = component.Render(r.Context(), w)
+	if err != nil {
+		log.Error().Err(err).Msg("Error rendering sync template")
+		http.Error(w, "Error rendering template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// conflict returns both sides of the conflicted path named by the "path"
+// form value, relative to the repository root. Only paths git reports as
+// conflicted are accepted. Callers hold gitMu.
+func (s *Server) conflict(r *http.Request) (vcs.Conflict, error) {
+	path := r.FormValue("path")
+
+	status, err := s.VCS.Status(r.Context())
+	if err != nil {
+		return vcs.Conflict{}, err
+	}
+	if !slices.ContainsFunc(status.Conflicts(), func(change vcs.Change) bool {
+		return change.Path == path
+	}) {
+		return vcs.Conflict{}, &pathError{path, "it has no conflicts"}
+	}
+
+	return s.VCS.Conflict(r.Context(), path)
+}
+
+// handleSyncConflict shows both sides of a conflicted file
+func (s *Server) handleSyncConflict(w http.ResponseWriter, r *http.Request) {
+	if s.VCS == nil {
+		http.Error(w, "The site isn't in a git repository", http.StatusNotFound)
+		return
+	}
+
+	s.gitMu.Lock()
+	conflict, err := s.conflict(r)
+	s.gitMu.Unlock()
+	if badPath(w, err) {
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Error reading conflict")
+		http.Error(w, "Error reading conflict: "+gitMessage(err), http.StatusInternalServerError)
+		return
+	}
+
+	data := templates.SyncConflictData{
+		Conflict: conflict,
+		Diff:     diff.Lines(conflict.Local, conflict.Upstream),
+		Merged:   diff.Merge(conflict.Local, conflict.Upstream, "your version", "remote version"),
+	}
+
+	component := templates.SyncConflict(data)
+	err = component.Render(r.Context(), w)
+	if err != nil {
+		log.Error().Err(err).Msg("Error rendering conflict template")
+		http.Error(w, "Error rendering template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleSyncResolve resolves a conflicted file with the chosen side, or
+// with the version merged by hand, and stages it
+func (s *Server) handleSyncResolve(w http.ResponseWriter, r *http.Request) {
+	if s.VCS == nil {
+		http.Error(w, "The site isn't in a git repository", http.StatusNotFound)
+		return
+	}
+	ctx := context.WithoutCancel(r.Context())
+
+	s.gitMu.Lock()
+	defer s.gitMu.Unlock()
+
+	conflict, err := s.conflict(r)
+	if badPath(w, err) {
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Error reading conflict")
+		http.Error(w, "Error reading conflict: "+gitMessage(err), http.StatusInternalServerError)
+		return
+	}
+
+	var content string
+	var deleted bool
+	switch r.FormValue("choice") {
+	case "local":
+		content, deleted = conflict.Local, conflict.LocalDeleted
+	case "upstream":
+		content, deleted = conflict.Upstream, conflict.UpstreamDeleted
+	case "merged":
+		// Browsers submit textareas with CRLF line endings
+		content = strings.ReplaceAll(r.FormValue("content"), "\r\n", "\n")
+	default:
+		http.Error(w, "Choose a version to keep", http.StatusBadRequest)
+		return
+	}
+
+	path := filepath.Join(s.VCS.Root(), filepath.FromSlash(conflict.Path))
+	if deleted {
+		err = os.Remove(path)
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+	} else {
+		err = posts.WriteFileAtomic(path, []byte(content))
+	}
+	if err == nil {
+		err = s.VCS.Add(ctx, conflict.Path)
+	}
+	if err != nil {
+		log.Error().Err(err).Str("path", conflict.Path).Msg("Error resolving conflict")
+		http.Error(w, "Error resolving conflict: "+gitMessage(err), http.StatusInternalServerError)
+		return
+	}
+
+	log.Info().Str("path", conflict.Path).Str("choice", r.FormValue("choice")).Msg("Conflict resolved")
+	flash.Set(w, r, flash.Success, "Resolved "+conflict.Path)
+	http.Redirect(w, r, "/sync", http.StatusSeeOther)
+}
+
+// handleSyncContinue finishes a sync once its conflicts are resolved
+func (s *Server) handleSyncContinue(w http.ResponseWriter, r *http.Request) {
+	if s.VCS == nil {
+		http.Error(w, "The site isn't in a git repository", http.StatusNotFound)
+		return
+	}
+
+	s.gitMu.Lock()
+	err := s.VCS.Continue(context.WithoutCancel(r.Context()))
+	s.gitMu.Unlock()
+
+	switch {
+	case errors.Is(err, vcs.ErrConflict):
+		// Rebasing replays the local commits one by one, each may conflict
+		log.Warn().Err(err).Msg("Sync stopped on more conflicts")
+		flash.Set(w, r, flash.Error, "More changes conflict, choose how to resolve them")
+	case err != nil:
+		log.Error().Err(err).Msg("Failed to finish sync")
+		flash.Set(w, r, flash.Error, "Finishing the sync failed: "+gitMessage(err))
+	default:
+		log.Info().Msg("Sync finished")
+		flash.Set(w, r, flash.Success, "Synced with the remote")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/sync", http.StatusSeeOther)
+}
+
+// handleSyncAbort gives up a sync stopped on conflicts
+func (s *Server) handleSyncAbort(w http.ResponseWriter, r *http.Request) {
+	if s.VCS == nil {
+		http.Error(w, "The site isn't in a git repository", http.StatusNotFound)
+		return
+	}
+
+	s.gitMu.Lock()
+	err := s.VCS.Abort(context.WithoutCancel(r.Context()))
+	s.gitMu.Unlock()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to abort sync")
+		flash.Set(w, r, flash.Error, "Aborting the sync failed: "+gitMessage(err))
+		http.Redirect(w, r, "/sync", http.StatusSeeOther)
+		return
+	}
+
+	log.Info().Msg("Sync aborted")
+	flash.Set(w, r, flash.Success, "Sync aborted, your branch is back to how it was")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
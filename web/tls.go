@@ -0,0 +1,71 @@
+package web
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+)
+
+// hstsHeader tells browsers to use HTTPS for the next year
+const hstsHeader = "max-age=31536000"
+
+// tlsEnabled reports whether the server is configured to serve HTTPS
+func (s *Server) tlsEnabled() bool {
+	return s.TLSCert != "" && s.TLSKey != ""
+}
+
+// tlsConfig returns the TLS settings of the HTTPS server
+func tlsConfig() *tls.Config {
+	return &tls.Config{MinVersion: tls.VersionTLS12}
+}
+
+// strictTransport adds the Strict-Transport-Security header to responses
+func strictTransport(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", hstsHeader)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// startRedirect listens on RedirectAddr and redirects the plain HTTP
+// requests it gets to the HTTPS server
+func (s *Server) startRedirect() (*http.Server, error) {
+	listener, err := net.Listen("tcp", s.RedirectAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	_, port, err := net.SplitHostPort(s.Addr)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host := r.Host
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+			}
+			if port != "443" {
+				host = net.JoinHostPort(host, port)
+			}
+			http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+		}),
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      readTimeout,
+		IdleTimeout:       idleTimeout,
+	}
+
+	log.Info().Str("address", s.RedirectAddr).Msg("Redirecting HTTP to HTTPS")
+	go func() {
+		if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+			log.Error().Err(err).Msg("HTTP redirect server failed")
+		}
+	}()
+	return server, nil
+}